@@ -0,0 +1,46 @@
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import "math"
+
+// arcballVector maps the raster point onto the virtual trackball, a sphere
+// centered on the raster that touches its shorter sides, and returns the
+// corresponding camera space unit vector. Points outside the sphere map
+// onto its silhouette.
+func arcballVector(x float64, y float64, width float64, height float64) V4 {
+	// raster y points down, which is camera space +y:
+	r := math.Min(width, height) / 2
+	v := V4{x: (x - width / 2) / r, y: (y - height / 2) / r}
+	if d := v.x * v.x + v.y * v.y; d < 1 {
+		v.z = math.Sqrt(1 - d)	// towards the viewer
+	} else {
+		v.Normalize()
+	}
+	return v
+}
+
+// Arcball returns the camera space axis and angle in radians of the
+// rotation that drags the trackball under the cursor from one raster point
+// to another. The angle is zero when the points coincide.
+func Arcball(x0 float64, y0 float64, x1 float64, y1 float64, width float64, height float64) (V4, float64) {
+	v0 := arcballVector(x0, y0, width, height)
+	v1 := arcballVector(x1, y1, width, height)
+	axis := Cross(v0, v1)
+	if axis.Length() == 0 {
+		return V4{z: 1}, 0
+	}
+	axis.w = 0
+	return *axis.Normalize(), Angle(v0, v1)
+}
@@ -0,0 +1,41 @@
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"math"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArcball(t *testing.T) {
+	// dragging right from the center rotates about the y-axis:
+	axis, angle := Arcball(100, 100, 150, 100, 200, 200)
+	assertAlmostEqualV4(t, V4{y: 1}, axis)
+	assert.InDelta(t, math.Asin(.5), angle, 1e-6)
+
+	// dragging down (raster y) rotates the front of the ball down:
+	axis, angle = Arcball(100, 100, 100, 200, 200, 200)
+	assertAlmostEqualV4(t, V4{x: -1}, axis)
+	assert.InDelta(t, math.Pi / 2, angle, 1e-6)
+	assertAlmostEqualV4(t, *NewV4(0, 1, 0), *NewV4(0, 0, 1).MultiplyM(Rot(NewV4(0, 0, 0), &axis, angle)))
+
+	// dragging along the edge rolls the ball about the z-axis:
+	axis, angle = Arcball(300, 100, 100, 300, 200, 200)
+	assertAlmostEqualV4(t, V4{z: 1}, axis)
+	assert.InDelta(t, math.Pi / 2, angle, 1e-6)
+
+	_, angle = Arcball(40, 70, 40, 70, 200, 100)
+	assert.Equal(t, 0., angle)
+}
@@ -0,0 +1,188 @@
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// AABB is an axis-aligned bounding box.
+type AABB struct {
+	min, max V4
+}
+
+// Center returns the center of the box.
+func (b AABB) Center() V4 {
+	return V4{
+		x: (b.min.x + b.max.x) / 2,
+		y: (b.min.y + b.max.y) / 2,
+		z: (b.min.z + b.max.z) / 2,
+		w: 1,
+	}
+}
+
+// Size returns the dimensions of the box along each axis.
+func (b AABB) Size() V4 {
+	return b.max.Subtract(b.min)
+}
+
+// Transform returns the axis-aligned box that contains this box after
+// applying the specified transformation to it.
+func (b AABB) Transform(m *M4) AABB {
+	var t AABB
+	for i := 0; i < 8; i++ {
+		corner := b.min
+		if i & 1 != 0 {
+			corner.x = b.max.x
+		}
+		if i & 2 != 0 {
+			corner.y = b.max.y
+		}
+		if i & 4 != 0 {
+			corner.z = b.max.z
+		}
+		corner.MultiplyM(m)
+		if i == 0 {
+			t.min, t.max = corner, corner
+		} else {
+			t.extend(corner)
+		}
+	}
+	return t
+}
+
+func (b *AABB) extend(v V4) {
+	b.min.x, b.max.x = math.Min(b.min.x, v.x), math.Max(b.max.x, v.x)
+	b.min.y, b.max.y = math.Min(b.min.y, v.y), math.Max(b.max.y, v.y)
+	b.min.z, b.max.z = math.Min(b.min.z, v.z), math.Max(b.max.z, v.z)
+}
+
+// Sphere is a bounding sphere.
+type Sphere struct {
+	center V4
+	radius float64
+}
+
+// Transform returns a sphere that contains this sphere after applying the
+// specified transformation to it. Non-uniform scaling enlarges the sphere
+// along all axes.
+func (s Sphere) Transform(m *M4) Sphere {
+	c := s.center
+	scale := math.Max(NewV4(m.a0, m.b0, m.c0).Length(),
+		math.Max(NewV4(m.a1, m.b1, m.c1).Length(), NewV4(m.a2, m.b2, m.c2).Length()))
+	return Sphere{center: *c.MultiplyM(m), radius: s.radius * scale}
+}
+
+// Bounds returns the model's axis-aligned bounding box, which is empty and
+// at the origin for models without triangles.
+func (m *Model) Bounds() AABB {
+	var b AABB
+	for i, t := range m.triangles {
+		if i == 0 {
+			b.min, b.max = t.v1, t.v1
+		}
+		b.extend(t.v1)
+		b.extend(t.v2)
+		b.extend(t.v3)
+	}
+	b.min.w, b.max.w = 1, 1
+	return b
+}
+
+// Normalize translates the model's center to the origin and scales it to
+// fit the ((-.5, -.5, -.5), (.5, .5, .5)) bounding box, preserving its
+// proportions. It returns itself.
+func (m *Model) Normalize() *Model {
+	if len(m.triangles) == 0 {
+		return m
+	}
+	b := m.Bounds()
+	center, size := b.Center(), b.Size()
+	m.Move(-center.x, -center.y, -center.z)
+
+	factor := 1. / math.Max(size.x, math.Max(size.y, size.z))
+	return m.Apply(ScaleM(factor, factor, factor))
+}
+
+// BoundingSphere returns a sphere that contains the entire model. It is
+// centered on the model's bounding box, which makes it quick to compute
+// but not the smallest possible sphere.
+func (m *Model) BoundingSphere() Sphere {
+	s := Sphere{center: m.Bounds().Center()}
+	for _, t := range m.triangles {
+		for _, v := range []V4{t.v1, t.v2, t.v3} {
+			d := v.Subtract(s.center)
+			s.radius = math.Max(s.radius, d.Length())
+		}
+	}
+	return s
+}
+
+// Split partitions the model into batches of at most n spatially close
+// triangles, by ordering them along the longest axis of the model's
+// bounding box. This allows for culling parts of large models.
+func (m *Model) Split(n int) []*Model {
+	size := m.Bounds().Size()
+	var axis = func(v V4) float64 {
+		if size.x >= size.y && size.x >= size.z {
+			return v.x
+		} else if size.y >= size.z {
+			return v.y
+		}
+		return v.z
+	}
+
+	sorted := m.Clone()
+	sort.SliceStable(sorted.triangles, func(i, j int) bool {
+		a, b := &sorted.triangles[i], &sorted.triangles[j]
+		return axis(a.v1) + axis(a.v2) + axis(a.v3) < axis(b.v1) + axis(b.v2) + axis(b.v3)
+	})
+
+	var batches []*Model
+	for i := 0; i < len(sorted.triangles); i += n {
+		end := int(math.Min(float64(i + n), float64(len(sorted.triangles))))
+		batches = append(batches, &Model{sorted.triangles[i:end]})
+	}
+	return batches
+}
+
+// Batch is a part of a model with a precomputed bounding sphere, allowing
+// it to be culled as a whole.
+type Batch struct {
+	model  *Model
+	bounds Sphere
+}
+
+// NewBatches splits the model into batches of at most n triangles.
+func NewBatches(model *Model, n int) []Batch {
+	var batches []Batch
+	for _, m := range model.Split(n) {
+		batches = append(batches, Batch{model: m, bounds: m.BoundingSphere()})
+	}
+	return batches
+}
+
+// Cull returns a new model holding the triangles of the batches that are
+// inside the frustum after transformation by m, transformed by m. Batches
+// that are entirely outside the frustum are never cloned or transformed.
+func Cull(batches []Batch, f *Frustum, m *M4) *Model {
+	var visible []Model
+	for i := range batches {
+		if f.IntersectsSphere(batches[i].bounds.Transform(m)) {
+			visible = append(visible, *batches[i].model)
+		}
+	}
+	return new(Model).Merge(visible...).Apply(m)
+}
@@ -0,0 +1,91 @@
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"math"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModel_Bounds(t *testing.T) {
+	b := Cube().Move(1, 2, 3).Bounds()
+	assertAlmostEqualV4(t, *NewV4(.5, 1.5, 2.5), b.min)
+	assertAlmostEqualV4(t, *NewV4(1.5, 2.5, 3.5), b.max)
+	assertAlmostEqualV4(t, *NewV4(1, 2, 3), b.Center())
+
+	assert.Equal(t, AABB{*NewV4(0, 0, 0), *NewV4(0, 0, 0)}, new(Model).Bounds())
+
+	// rotating the box by 45 degrees widens it by a factor of sqrt(2):
+	b = Cube().Bounds().Transform(RotZ(rad(45)))
+	assertAlmostEqualV4(t, *NewV4(-math.Sqrt2 / 2, -math.Sqrt2 / 2, -.5), b.min)
+	assertAlmostEqualV4(t, *NewV4(math.Sqrt2 / 2, math.Sqrt2 / 2, .5), b.max)
+}
+
+func TestModel_BoundingSphere(t *testing.T) {
+	s := Cube().Move(1, 2, 3).BoundingSphere()
+	assertAlmostEqualV4(t, *NewV4(1, 2, 3), s.center)
+	assert.InDelta(t, math.Sqrt(.75), s.radius, 1e-6)
+
+	s = s.Transform(ScaleM(1, 3, 2).Mul(TransM(NewV4(-1, -2, -3))))
+	assertAlmostEqualV4(t, *NewV4(0, 0, 0), s.center)
+	assert.InDelta(t, 3 * math.Sqrt(.75), s.radius, 1e-6)
+}
+
+func TestModel_Normalize(t *testing.T) {
+	b := Cube().Apply(ScaleM(4, 2, 1)).Move(5, 5, 5).Normalize().Bounds()
+	assertAlmostEqualV4(t, *NewV4(-.5, -.25, -.125), b.min)
+	assertAlmostEqualV4(t, *NewV4(.5, .25, .125), b.max)
+}
+
+func TestModel_Split(t *testing.T) {
+	model := Cube().Merge(*Cube().Move(10, 0, 0))
+	batches := model.Split(12)
+	assert.Len(t, batches, 2)
+
+	// both cubes end up in their own batch:
+	assertAlmostEqualV4(t, *NewV4(0, 0, 0), batches[0].Bounds().Center())
+	assertAlmostEqualV4(t, *NewV4(10, 0, 0), batches[1].Bounds().Center())
+
+	assert.Len(t, model.Split(5), 5)
+}
+
+func TestFrustum(t *testing.T) {
+	f := NewProjector(100, 90).Frustum()
+
+	assert.True(t, f.IntersectsSphere(Sphere{*NewV4(0, 0, -5), 1}))
+	assert.True(t, f.IntersectsSphere(Sphere{*NewV4(0, 0, .5), 1}))
+	assert.False(t, f.IntersectsSphere(Sphere{*NewV4(0, 0, 2), 1}))
+	assert.True(t, f.IntersectsSphere(Sphere{*NewV4(5.5, 0, -5), 1}))
+	assert.False(t, f.IntersectsSphere(Sphere{*NewV4(6.5, 0, -5), 1}))
+	assert.False(t, f.IntersectsSphere(Sphere{*NewV4(0, -6.5, -5), 1}))
+
+	assert.True(t, f.IntersectsAABB(Cube().Move(0, 0, -5).Bounds()))
+	assert.True(t, f.IntersectsAABB(Cube().Move(4.9, 0, -5).Bounds()))
+	assert.False(t, f.IntersectsAABB(Cube().Move(6.1, 0, -5).Bounds()))
+	assert.False(t, f.IntersectsAABB(Cube().Move(0, 0, 1).Bounds()))
+}
+
+func TestCull(t *testing.T) {
+	batches := NewBatches(Cube().Merge(*Cube().Move(10, 0, 0)), 12)
+	f := NewProjector(100, 90).Frustum()
+
+	model := Cull(batches, f, TransM(NewV4(0, 0, -3)))
+	assert.Len(t, model.triangles, 12)
+	assertAlmostEqualV4(t, *NewV4(0, 0, -3), model.Bounds().Center())
+
+	assert.Len(t, Cull(batches, f, TransM(NewV4(-10, 0, -3))).triangles, 12)
+	assert.Len(t, Cull(batches, f, TransM(NewV4(-5, 0, -12))).triangles, 24)
+	assert.Len(t, Cull(batches, f, TransM(NewV4(0, 0, 3))).triangles, 0)
+}
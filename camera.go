@@ -0,0 +1,184 @@
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import "math"
+
+// Camera is a viewpoint in world space, looking from its position at its
+// target with the specified up direction.
+type Camera struct {
+	position V4
+	target   V4
+	up       V4
+}
+
+// minDistance is the distance the camera keeps from its target.
+const minDistance = .1
+
+// NewCamera returns a camera at `position` looking at `target`.
+func NewCamera(position *V4, target *V4, up *V4) *Camera {
+	return &Camera{position: *position, target: *target, up: *up}
+}
+
+// degenerate is the length below which the cross product of the view
+// direction and the up vector is considered zero.
+const degenerate = 1e-12
+
+// perpendicular returns a unit vector perpendicular to `v`.
+func perpendicular(v V4) V4 {
+	// crossing with the axis v is least aligned with avoids cancellation:
+	axis := V4{x: 1}
+	if ax, ay, az := math.Abs(v.x), math.Abs(v.y), math.Abs(v.z); ay < ax && ay <= az {
+		axis = V4{y: 1}
+	} else if az < ax && az < ay {
+		axis = V4{z: 1}
+	}
+	p := Cross(v, axis)
+	return *p.Normalize()
+}
+
+// viewBasis returns the unit forward and right vectors of an eye looking
+// from `eye` at `target` with the specified up vector. Where these are
+// undefined, because the eye is on its target or looks along the up
+// vector, a perpendicular of the up vector is used as the forward or right
+// vector, respectively.
+func viewBasis(eye V4, target V4, up V4) (V4, V4) {
+	f := target.Subtract(eye)
+	if f.Length() < degenerate {
+		f = perpendicular(up)
+	}
+	f.Normalize()
+	r := Cross(f, up)
+	if r.Length() < degenerate {
+		r = perpendicular(f)
+	}
+	return f, *r.Normalize()
+}
+
+// LookAt returns a view matrix that transforms world space into the camera
+// space of an eye at `eye` looking at `target`, in which the eye is at the
+// origin looking down the negative z-axis with `up` along the positive
+// y-axis. See viewBasis for when the eye looks along `up`.
+func LookAt(eye *V4, target *V4, up *V4) *M4 {
+	f, r := viewBasis(*eye, *target, *up)
+	u := Cross(r, f)
+
+	return &M4{
+		r.x, r.y, r.z, -Dot(r, *eye),
+		u.x, u.y, u.z, -Dot(u, *eye),
+		-f.x, -f.y, -f.z, Dot(f, *eye),
+		0, 0, 0, 1}
+}
+
+// View returns the camera's view matrix.
+func (c *Camera) View() *M4 {
+	return LookAt(&c.position, &c.target, &c.up)
+}
+
+// Position returns the camera's position in world space.
+func (c *Camera) Position() V4 {
+	return c.position
+}
+
+// Target returns the point in world space the camera is looking at.
+func (c *Camera) Target() V4 {
+	return c.target
+}
+
+// Distance returns the distance between the camera and its target.
+func (c *Camera) Distance() float64 {
+	d := c.target.Subtract(c.position)
+	return d.Length()
+}
+
+// LookAt points the camera at the specified target and returns itself.
+func (c *Camera) LookAt(target *V4) *Camera {
+	c.target = *target
+	return c
+}
+
+// forward returns the unit vector the camera is looking along.
+func (c *Camera) forward() V4 {
+	f, _ := viewBasis(c.position, c.target, c.up)
+	return f
+}
+
+// right returns the unit vector pointing to the right of the camera.
+func (c *Camera) right() V4 {
+	_, r := viewBasis(c.position, c.target, c.up)
+	return r
+}
+
+// limitPitch limits the pitch angle so that the view direction `v` does not
+// rotate past the camera's up vector, where the view matrix degenerates.
+func (c *Camera) limitPitch(v V4, pitch float64) float64 {
+	const margin = 1e-3
+	angle := Angle(v, c.up)	// between 0 (straight up) and Pi
+	return math.Max(-(math.Pi - margin - angle), math.Min(angle - margin, pitch))
+}
+
+// Orbit rotates the camera around its target, by `yaw` radians about the up
+// vector and by `pitch` radians about the camera's right vector, and
+// returns itself. Positive pitch moves the camera up.
+func (c *Camera) Orbit(yaw float64, pitch float64) *Camera {
+	r := c.right()
+	pitch = c.limitPitch(c.position.Subtract(c.target), pitch)
+
+	c.position.MultiplyM(Rot(&c.target, &c.up, yaw).Mul(Rot(&c.target, &r, -pitch)))
+	return c
+}
+
+// Pan moves both the camera and its target along the camera's right and up
+// vectors and returns itself.
+func (c *Camera) Pan(dx float64, dy float64) *Camera {
+	r, f := c.right(), c.forward()
+	u := Cross(r, f)
+	m := TransM(&V4{
+		x: r.x * dx + u.x * dy,
+		y: r.y * dx + u.y * dy,
+		z: r.z * dx + u.z * dy,
+	})
+	c.position.MultiplyM(m)
+	c.target.MultiplyM(m)
+	return c
+}
+
+// Dolly moves the camera along its view direction by `d` and returns
+// itself. The camera never moves past its target; instead the target is
+// pushed ahead, so that dollying forward continues indefinitely.
+func (c *Camera) Dolly(d float64) *Camera {
+	f := c.forward()
+	c.position.MultiplyM(TransM(NewV4(f.x * d, f.y * d, f.z * d)))
+
+	if dist := Dot(c.target.Subtract(c.position), f); dist < minDistance {
+		push := minDistance - dist
+		c.target.MultiplyM(TransM(NewV4(f.x * push, f.y * push, f.z * push)))
+	}
+	return c
+}
+
+// Yaw turns the camera in place by `a` radians about its up vector, moving
+// its target, and returns itself.
+func (c *Camera) Yaw(a float64) *Camera {
+	c.target.MultiplyM(Rot(&c.position, &c.up, a))
+	return c
+}
+
+// Pitch tilts the camera in place by `a` radians about its right vector,
+// moving its target, and returns itself. Positive angles tilt upwards.
+func (c *Camera) Pitch(a float64) *Camera {
+	r := c.right()
+	c.target.MultiplyM(Rot(&c.position, &r, c.limitPitch(c.forward(), a)))
+	return c
+}
@@ -0,0 +1,103 @@
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"math"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookAt(t *testing.T) {
+	origin, up := NewV4(0, 0, 0), NewV4(0, 1, 0)
+
+	// looking down the negative z-axis is a plain translation:
+	assertAlmostEqualM4(t, TransM(NewV4(0, 0, -2)), LookAt(NewV4(0, 0, 2), origin, up), 1e-6)
+
+	// the target ends up straight ahead, at its distance from the eye:
+	eye := NewV4(3, -1, 4)
+	m := LookAt(eye, NewV4(1, 1, 1), up)
+	assertAlmostEqualV4(t, *NewV4(0, 0, -math.Sqrt(17)), *NewV4(1, 1, 1).MultiplyM(m))
+	assertAlmostEqualV4(t, *origin, *eye.MultiplyM(m))
+	assert.InDelta(t, 1., m.Determinant(), 1e-6)
+
+	// the up vector stays in the y-z plane:
+	v := NewV4(3, 0, 4).MultiplyM(m)
+	assert.InDelta(t, 0., v.x, 1e-6)
+	assert.True(t, v.y > 0)
+}
+
+func TestCamera_Orbit(t *testing.T) {
+	c := NewCamera(NewV4(0, 0, 2), NewV4(0, 0, 0), NewV4(0, 1, 0))
+	c.Orbit(rad(90), 0)
+	assertAlmostEqualV4(t, *NewV4(2, 0, 0), c.Position())
+	assertAlmostEqualV4(t, *NewV4(0, 0, -2), *NewV4(0, 0, 0).MultiplyM(c.View()))
+
+	c.Orbit(0, rad(45))
+	assertAlmostEqualV4(t, *NewV4(math.Sqrt2, math.Sqrt2, 0), c.Position())
+
+	// pitch stops short of the up vector:
+	c.Orbit(0, rad(80))
+	assert.InDelta(t, 2., c.Position().y, 1e-3)
+	assert.True(t, c.Position().y < 2)
+	assertAlmostEqualV4(t, *NewV4(0, 0, 0), c.Target())
+}
+
+func TestCamera_PanDolly(t *testing.T) {
+	c := NewCamera(NewV4(0, 0, 2), NewV4(0, 0, 0), NewV4(0, 1, 0))
+	c.Pan(1, -1)
+	assertAlmostEqualV4(t, *NewV4(1, -1, 2), c.Position())
+	assertAlmostEqualV4(t, *NewV4(1, -1, 0), c.Target())
+
+	c.Dolly(1.5)
+	assertAlmostEqualV4(t, *NewV4(1, -1, .5), c.Position())
+	assertAlmostEqualV4(t, *NewV4(1, -1, 0), c.Target())
+
+	// moving past the target pushes it ahead:
+	c.Dolly(1)
+	assertAlmostEqualV4(t, *NewV4(1, -1, -.5), c.Position())
+	assertAlmostEqualV4(t, *NewV4(1, -1, -.5 - minDistance), c.Target())
+
+	c.Dolly(-2)
+	assertAlmostEqualV4(t, *NewV4(1, -1, 1.5), c.Position())
+}
+
+func TestCamera_YawPitch(t *testing.T) {
+	c := NewCamera(NewV4(0, 0, 2), NewV4(0, 0, 0), NewV4(0, 1, 0))
+	c.Yaw(rad(90))
+	assertAlmostEqualV4(t, *NewV4(0, 0, 2), c.Position())
+	assertAlmostEqualV4(t, *NewV4(-2, 0, 2), c.Target())
+
+	c.Pitch(rad(30))
+	assertAlmostEqualV4(t, *NewV4(-math.Sqrt(3), 1, 2), c.Target())
+}
+
+func TestCamera_Degenerate(t *testing.T) {
+	// looking straight down along the up vector:
+	c := NewCamera(NewV4(0, 3, 0), NewV4(0, 0, 0), NewV4(0, 1, 0))
+	m := c.View()
+	assert.InDelta(t, 1., m.Determinant(), 1e-6)
+	assertAlmostEqualV4(t, *NewV4(0, 0, -3), *NewV4(0, 0, 0).MultiplyM(m))
+
+	// rotations have an axis to rotate about:
+	assert.NotPanics(t, func() { c.Orbit(rad(10), rad(-10)) })
+	assert.InDelta(t, 3., c.Distance(), 1e-6)
+	assert.True(t, c.Position().y < 3)
+	assert.NotPanics(t, func() { c.Pitch(rad(10)) })
+
+	// a camera on its target:
+	c = NewCamera(NewV4(1, 1, 1), NewV4(1, 1, 1), NewV4(0, 1, 0))
+	assert.InDelta(t, 1., c.View().Determinant(), 1e-6)
+	assert.NotPanics(t, func() { c.Orbit(rad(10), rad(10)); c.Pitch(rad(10)) })
+}
@@ -0,0 +1,57 @@
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import "image/color"
+
+// Canvas is a 2D drawing surface in raster (pixel) coordinates that the
+// rendering pipeline draws onto. Figures are built up into a path with
+// MoveTo, LineTo and ClosePath, which is then consumed by Stroke or Fill.
+type Canvas interface {
+	// Clear erases the entire canvas to its background color.
+	Clear()
+
+	// SetColor sets the color used by subsequent strokes and fills.
+	SetColor(c color.Color)
+
+	// MoveTo starts a new figure at the specified point.
+	MoveTo(x float64, y float64)
+
+	// LineTo adds a line from the current point to the specified point.
+	LineTo(x float64, y float64)
+
+	// ClosePath closes the current figure with a line to its start point.
+	ClosePath()
+
+	// Stroke draws the outlines of the current path and then discards it.
+	Stroke()
+
+	// Fill fills the figures in the current path and then discards it.
+	Fill()
+}
+
+// drawPolygon adds the specified polygon in raster coordinates to the
+// canvas' current path.
+func drawPolygon(c Canvas, polygon []V4) {
+	for i, v := range polygon {
+		if i == 0 {
+			c.MoveTo(v.x, v.y)
+		} else {
+			c.LineTo(v.x, v.y)
+		}
+	}
+	if len(polygon) > 0 {
+		c.ClosePath()
+	}
+}
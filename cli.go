@@ -0,0 +1,449 @@
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// command is a subcommand of the command-line interface.
+type command struct {
+	name    string
+	args    string	// synopsis of the positional arguments
+	summary string
+	run     func(fs *flag.FlagSet, args []string, stdout io.Writer) error
+}
+
+var commands = []command{
+	{"view", "[model]", "shows the model's turntable rotation in a window", runView},
+	{"render", "-o file [model]", "renders the model to a PNG, SVG or animated GIF file", runRender},
+	{"convert", "-o file model", "converts the model to another file format", runConvert},
+	{"info", "model", "prints statistics of the model", runInfo},
+}
+
+// run executes the command-line interface with the specified arguments,
+// excluding the program name, and returns the exit status. Without a known
+// subcommand, the arguments are passed to `view`.
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	cmd := &commands[0]
+	if len(args) > 0 {
+		if args[0] == "help" || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+			usage(stdout)
+			return 0
+		}
+		for i := range commands {
+			if commands[i].name == args[0] {
+				cmd, args = &commands[i], args[1:]
+				break
+			}
+		}
+	}
+
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: 3dgo %s [flags] %s\n\n%s.\n\nflags:\n", cmd.name, cmd.args, cmd.summary)
+		fs.PrintDefaults()
+	}
+	if err := cmd.run(fs, args, stdout); err == flag.ErrHelp {
+		return 0
+	} else if err != nil {
+		fmt.Fprintf(stderr, "3dgo %s: %v\n", cmd.name, err)
+		return 1
+	}
+	return 0
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: 3dgo <command> [flags] [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Use "3dgo <command> -h" for the flags of a command.`)
+}
+
+// vectorFlag is a flag.Value of the form "x,y,z".
+type vectorFlag V4
+
+func (v *vectorFlag) String() string {
+	return fmt.Sprintf("%g,%g,%g", v.x, v.y, v.z)
+}
+
+func (v *vectorFlag) Set(s string) error {
+	fields := strings.Split(s, ",")
+	if len(fields) != 3 {
+		return errors.New("expected x,y,z")
+	}
+	var coords [3]float64
+	for i, f := range fields {
+		c, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil {
+			return fmt.Errorf("invalid coordinate %q", f)
+		}
+		coords[i] = c
+	}
+	*v = vectorFlag(*NewV4(coords[0], coords[1], coords[2]))
+	return nil
+}
+
+// hiddenLines maps the values of the -hidden flag.
+var hiddenLines = map[string]HiddenLines{
+	"cull":   CullBackFaces,
+	"remove": RemoveHidden,
+	"dash":   DashHidden,
+}
+
+// settings holds the flags of the commands that draw models.
+type settings struct {
+	width        int
+	height       int
+	fov          float64	// horizontal, in degrees
+	camera       vectorFlag
+	target       vectorFlag
+	period       float64	// seconds per rotation
+	tilt         float64	// of the rotation axis, in degrees
+	solid        bool
+	hideCoplanar bool
+	hidden       string
+}
+
+func (s *settings) register(fs *flag.FlagSet) {
+	s.camera = vectorFlag(*NewV4(0, 0, 2))
+	s.target = vectorFlag(*NewV4(0, 0, 0))
+	fs.IntVar(&s.width, "width", 800, "width of the image in pixels")
+	fs.IntVar(&s.height, "height", 600, "height of the image in pixels")
+	fs.Float64Var(&s.fov, "fov", 52, "horizontal field of view in degrees")
+	fs.Var(&s.camera, "camera", "camera position as x,y,z")
+	fs.Var(&s.target, "target", "point the camera looks at as x,y,z")
+	fs.Float64Var(&s.period, "period", 30, "seconds per full rotation")
+	fs.Float64Var(&s.tilt, "tilt", 23.4, "tilt of the rotation axis towards the camera in degrees")
+	fs.BoolVar(&s.solid, "solid", false, "draw flat shaded faces rather than a wireframe")
+	fs.BoolVar(&s.hideCoplanar, "quads", false, "hide wireframe edges between coplanar triangles")
+	fs.StringVar(&s.hidden, "hidden", "cull", "wireframe hidden lines: cull (back faces only), remove or dash")
+}
+
+func (s *settings) validate() error {
+	if s.width <= 0 || s.height <= 0 {
+		return fmt.Errorf("invalid resolution %dx%d", s.width, s.height)
+	}
+	if s.fov <= 0 || s.fov >= 180 {
+		return fmt.Errorf("invalid field of view %g", s.fov)
+	}
+	if s.period <= 0 {
+		return fmt.Errorf("invalid rotation period %g", s.period)
+	}
+	if _, ok := hiddenLines[s.hidden]; !ok {
+		return fmt.Errorf("invalid hidden line mode %q", s.hidden)
+	}
+	// the view is undefined when looking straight up or down:
+	position, target := V4(s.camera), V4(s.target)
+	d := target.Subtract(position)
+	if d.Length() == 0 {
+		return errors.New("camera and target coincide")
+	}
+	if c := Cross(d, cameraUp); c.Length() <= 1e-9 * d.Length() {
+		return fmt.Errorf("camera looks along the up vector %g,%g,%g", cameraUp.x, cameraUp.y, cameraUp.z)
+	}
+	return nil
+}
+
+func (s *settings) projector() *Projector {
+	return NewPerspectiveProjector(s.width, s.height, s.fov, HorizontalFOV)
+}
+
+// cameraUp is the up vector of the command-line camera.
+var cameraUp = *NewV4(0, 1, 0)
+
+func (s *settings) newCamera() *Camera {
+	position, target, up := V4(s.camera), V4(s.target), cameraUp
+	return NewCamera(&position, &target, &up)
+}
+
+func (s *settings) hiddenLines() HiddenLines {
+	return hiddenLines[s.hidden]
+}
+
+// loadModel reads the model file, picking the format by its extension.
+// Without a path, it returns the default cube.
+func loadModel(path string, scale bool) (*Model, error) {
+	if path == "" {
+		return Cube().Rot(math.Pi / 4, math.Pi / 4, math.Pi / 4), nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".stl":
+		return NewSTLReader(f).ReadModel(scale)
+	case ".obj":
+		return NewOBJReader(f).ReadModel(scale)
+	case ".ply":
+		return NewPLYReader(f).ReadModel(scale)
+	case ".off":
+		return NewOFFReader(f).ReadModel(scale)
+	case ".3mf":
+		info, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		r, err := NewThreeMFReader(f, info.Size())
+		if err != nil {
+			return nil, err
+		}
+		return r.ReadModel(scale)
+	default:
+		return nil, fmt.Errorf("%s: unsupported model format %q", path, ext)
+	}
+}
+
+// saveModel writes the model in the specified format, which is the file
+// name's extension when empty. Use `normals` to include face normals in
+// formats where they are optional.
+func saveModel(path string, format string, model *Model, normals bool, stdout io.Writer) error {
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+	// the format is checked before the output file is created:
+	write, err := modelWriter(format, normals)
+	if err != nil {
+		return err
+	}
+	return writeOutput(path, stdout, func(w io.Writer) error {
+		return write(w, model)
+	})
+}
+
+// modelWriter returns the function that writes models in the format.
+func modelWriter(format string, normals bool) (func(w io.Writer, model *Model) error, error) {
+	switch format {
+	case "stl":
+		return func(w io.Writer, model *Model) error { return NewSTLWriter(w, false).WriteModel(model) }, nil
+	case "stlb":
+		return func(w io.Writer, model *Model) error { return NewSTLWriter(w, true).WriteModel(model) }, nil
+	case "obj":
+		return func(w io.Writer, model *Model) error { return NewOBJWriter(w, normals).WriteModel(model) }, nil
+	case "ply":
+		return func(w io.Writer, model *Model) error { return NewPLYWriter(w, PLYBinaryLittleEndian).WriteModel(model) }, nil
+	case "plya":
+		return func(w io.Writer, model *Model) error { return NewPLYWriter(w, PLYASCII).WriteModel(model) }, nil
+	case "off":
+		return func(w io.Writer, model *Model) error { return NewOFFWriter(w).WriteModel(model) }, nil
+	default:
+		return nil, fmt.Errorf("unsupported model format %q", format)
+	}
+}
+
+// writeOutput creates the file, or uses stdout for "-", and passes it to
+// write. When writing fails, the incomplete file is removed.
+func writeOutput(path string, stdout io.Writer, write func(w io.Writer) error) error {
+	if path == "-" {
+		return write(stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+// modelArg returns the single optional positional argument.
+func modelArg(fs *flag.FlagSet, required bool) (string, error) {
+	switch {
+	case fs.NArg() > 1:
+		return "", fmt.Errorf("unexpected arguments %q", fs.Args()[1:])
+	case fs.NArg() == 0 && required:
+		return "", errors.New("missing model file")
+	}
+	return fs.Arg(0), nil
+}
+
+func runView(fs *flag.FlagSet, args []string, stdout io.Writer) error {
+	var s settings
+	s.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := s.validate(); err != nil {
+		return err
+	}
+	path, err := modelArg(fs, false)
+	if err != nil {
+		return err
+	}
+	model, err := loadModel(path, true)
+	if err != nil {
+		return err
+	}
+	return viewModel(model, &s)
+}
+
+func runRender(fs *flag.FlagSet, args []string, stdout io.Writer) error {
+	var s settings
+	s.register(fs)
+	output := fs.String("o", "", "output file, or - for stdout")
+	format := fs.String("format", "", "output format: png, svg or gif (default: the output file's extension)")
+	angle := fs.Float64("angle", 0, "turntable rotation in degrees of a still image or the first frame of an animation")
+	frames := fs.Int("frames", 36, "number of frames of an animation")
+	stroke := fs.Float64("stroke", 1, "stroke width of SVG wireframes in pixels")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := s.validate(); err != nil {
+		return err
+	}
+	if *output == "" {
+		return errors.New("missing output file")
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*output)), ".")
+	}
+	if *frames <= 0 {
+		return fmt.Errorf("invalid number of frames %d", *frames)
+	}
+	path, err := modelArg(fs, false)
+	if err != nil {
+		return err
+	}
+	model, err := loadModel(path, true)
+	if err != nil {
+		return err
+	}
+
+	p, camera := s.projector(), s.newCamera()
+	m := camera.View().Mul(Turntable(rad(s.tilt), rad(*angle)))
+	switch *format {
+	case "png":
+		return writeOutput(*output, stdout, func(w io.Writer) error {
+			r := NewRaster(s.width, s.height)
+			if s.solid {
+				DrawSolid(r, p, model.Clone().Apply(m), NewLight(NewV4(1, 1, -1), .2),
+					color.RGBA{0x70, 0x90, 0xc0, 0xff})
+			} else {
+				NewWireframe(model).Draw(r, p, m, s.hideCoplanar, s.hiddenLines())
+			}
+			return r.WritePNG(w)
+		})
+	case "svg":
+		if s.solid {
+			return errors.New("SVG output only supports wireframes")
+		}
+		return writeOutput(*output, stdout, func(w io.Writer) error {
+			// the turntable rotation is applied to the model as the
+			// exporter only takes a camera:
+			return ExportSVG(w, model.Clone().Apply(Turntable(rad(s.tilt), rad(*angle))), camera, p, SVGOptions{
+				StrokeWidth:  *stroke,
+				HideCoplanar: s.hideCoplanar,
+				Hidden:       s.hiddenLines(),
+			})
+		})
+	case "gif":
+		// GIF delays are in 100ths of a second:
+		delay := int(math.Round(s.period * 100 / float64(*frames)))
+		if delay < 1 {
+			return fmt.Errorf("a period of %gs is too short for %d frames", s.period, *frames)
+		}
+		return writeOutput(*output, stdout, func(w io.Writer) error {
+			return ExportGIF(w, model, camera, p, GIFOptions{
+				Frames:       *frames,
+				Delay:        delay,
+				Tilt:         rad(s.tilt),
+				Start:        rad(*angle),
+				Solid:        s.solid,
+				HideCoplanar: s.hideCoplanar,
+				Hidden:       s.hiddenLines(),
+			})
+		})
+	default:
+		return fmt.Errorf("unsupported output format %q", *format)
+	}
+}
+
+func runConvert(fs *flag.FlagSet, args []string, stdout io.Writer) error {
+	output := fs.String("o", "", "output file, or - for stdout")
+	format := fs.String("format", "", "output format: stl (ASCII), stlb (binary STL), obj, ply (binary), plya (ASCII PLY) or off (default: the output file's extension)")
+	normals := fs.Bool("normals", false, "write face normals to OBJ files")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *output == "" {
+		return errors.New("missing output file")
+	}
+	path, err := modelArg(fs, true)
+	if err != nil {
+		return err
+	}
+	model, err := loadModel(path, false)
+	if err != nil {
+		return err
+	}
+	return saveModel(*output, *format, model, *normals, stdout)
+}
+
+func runInfo(fs *flag.FlagSet, args []string, stdout io.Writer) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	path, err := modelArg(fs, true)
+	if err != nil {
+		return err
+	}
+	model, err := loadModel(path, false)
+	if err != nil {
+		return err
+	}
+
+	w := NewWireframe(model)
+	boundary, nonManifold := 0, 0
+	for _, e := range w.edges {
+		switch {
+		case len(e.faces) == 1:
+			boundary++
+		case len(e.faces) > 2:
+			nonManifold++
+		}
+	}
+	b := model.Bounds()
+	size := b.Size()
+	fmt.Fprintf(stdout, "triangles:          %d\n", len(model.triangles))
+	fmt.Fprintf(stdout, "vertices:           %d\n", len(w.mesh.vertices))
+	fmt.Fprintf(stdout, "edges:              %d\n", len(w.edges))
+	fmt.Fprintf(stdout, "boundary edges:     %d\n", boundary)
+	fmt.Fprintf(stdout, "non-manifold edges: %d\n", nonManifold)
+	fmt.Fprintf(stdout, "min:                %g %g %g\n", b.min.x, b.min.y, b.min.z)
+	fmt.Fprintf(stdout, "max:                %g %g %g\n", b.max.x, b.max.y, b.max.z)
+	fmt.Fprintf(stdout, "size:               %g %g %g\n", size.x, size.y, size.z)
+	return nil
+}
@@ -0,0 +1,151 @@
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+// runCLI runs the command-line interface and returns its exit status and
+// output.
+func runCLI(args ...string) (int, string, string) {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	status := run(args, stdout, stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+func TestCLI_Info(t *testing.T) {
+	status, out, _ := runCLI("info", "models/cube.stl")
+	assert.Equal(t, 0, status)
+	assert.Contains(t, out, "triangles:          12\n")
+	assert.Contains(t, out, "vertices:           8\n")
+	assert.Contains(t, out, "edges:              18\n")
+	assert.Contains(t, out, "boundary edges:     0\n")
+
+	status, _, errs := runCLI("info")
+	assert.Equal(t, 1, status)
+	assert.Equal(t, "3dgo info: missing model file\n", errs)
+
+	status, _, errs = runCLI("info", "models/missing.stl")
+	assert.Equal(t, 1, status)
+	assert.Contains(t, errs, "no such file")
+}
+
+func TestCLI_Render(t *testing.T) {
+	dir := t.TempDir()
+
+	status, _, errs := runCLI("render", "-width", "64", "-height", "48", "-o", filepath.Join(dir, "cube.png"), "models/cube.stl")
+	assert.Equal(t, 0, status, errs)
+	f, err := os.Open(filepath.Join(dir, "cube.png"))
+	assert.NoError(t, err)
+	img, err := png.Decode(f)
+	f.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, 64, img.Bounds().Dx())
+	assert.Equal(t, 48, img.Bounds().Dy())
+
+	status, out, errs := runCLI("render", "-width", "64", "-height", "48", "-format", "svg", "-o", "-", "-quads", "-hidden", "dash")
+	assert.Equal(t, 0, status, errs)
+	assert.True(t, strings.HasPrefix(out, "<?xml"))
+
+	status, out, errs = runCLI("render", "-width", "32", "-height", "32", "-frames", "5", "-period", "2", "-solid", "-o", "-", "-format", "gif")
+	assert.Equal(t, 0, status, errs)
+	anim, err := gif.DecodeAll(strings.NewReader(out))
+	assert.NoError(t, err)
+	assert.Len(t, anim.Image, 5)
+	assert.Equal(t, 40, anim.Delay[0])
+
+	status, _, errs = runCLI("render", "-frames", "360", "-period", "1", "-o", "-", "-format", "gif")
+	assert.Equal(t, 1, status)
+	assert.Equal(t, "3dgo render: a period of 1s is too short for 360 frames\n", errs)
+
+	status, _, errs = runCLI("render", "-o", filepath.Join(dir, "cube.jpg"))
+	assert.Equal(t, 1, status)
+	assert.Equal(t, "3dgo render: unsupported output format \"jpg\"\n", errs)
+
+	status, _, errs = runCLI("render", "-camera", "1,2", "-o", "-")
+	assert.Equal(t, 1, status)
+	assert.Contains(t, errs, "expected x,y,z")
+
+	status, _, errs = runCLI("render", "-camera", "0,3,0", "-o", "-", "-format", "png")
+	assert.Equal(t, 1, status)
+	assert.Equal(t, "3dgo render: camera looks along the up vector 0,1,0\n", errs)
+
+	status, _, errs = runCLI("render", "-camera", "1,1,1", "-target", "1,1,1", "-o", "-", "-format", "png")
+	assert.Equal(t, 1, status)
+	assert.Equal(t, "3dgo render: camera and target coincide\n", errs)
+
+	status, _, errs = runCLI("render", "-hidden", "some", "-o", "-")
+	assert.Equal(t, 1, status)
+	assert.Contains(t, errs, "invalid hidden line mode")
+}
+
+func TestCLI_Convert(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cube.stl")
+	status, _, errs := runCLI("convert", "-format", "stlb", "-o", path, "models/cube.stl")
+	assert.Equal(t, 0, status, errs)
+
+	model, err := loadModel(path, false)
+	assert.NoError(t, err)
+	original, _ := loadModel("models/cube.stl", false)
+	// binary STL stores single precision floats:
+	assertAlmostEqualV4(t, original.Bounds().min, model.Bounds().min)
+	assertAlmostEqualV4(t, original.Bounds().max, model.Bounds().max)
+
+	status, out, errs := runCLI("convert", "-o", "-", "-format", "obj", "-normals", "models/cube.stl")
+	assert.Equal(t, 0, status, errs)
+	assert.Equal(t, 6, strings.Count(out, "\nvn "))
+
+	// unsupported formats leave existing files alone:
+	path = filepath.Join(t.TempDir(), "cube.xyz")
+	assert.NoError(t, os.WriteFile(path, []byte("xyz"), 0644))
+	status, _, errs = runCLI("convert", "-o", path, "models/cube.stl")
+	assert.Equal(t, 1, status)
+	assert.Equal(t, "3dgo convert: unsupported model format \"xyz\"\n", errs)
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "xyz", string(data))
+}
+
+func TestWriteOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cube.stl")
+	err := writeOutput(path, nil, func(w io.Writer) error {
+		w.Write([]byte("solid "))
+		return errors.New("failed")
+	})
+	assert.EqualError(t, err, "failed")
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCLI_Usage(t *testing.T) {
+	status, out, _ := runCLI("help")
+	assert.Equal(t, 0, status)
+	for _, c := range commands {
+		assert.Contains(t, out, c.name)
+	}
+
+	status, _, errs := runCLI("render", "-h")
+	assert.Equal(t, 0, status)
+	assert.Contains(t, errs, "usage: 3dgo render")
+}
@@ -14,6 +14,7 @@
 package main
 
 import (
+	"image/color"
 	"math"
 )
 
@@ -78,6 +79,16 @@ func (v *V4) MultiplyM(m *M4) *V4 {
 	return v
 }
 
+// PerspectiveDivide divides this homogeneous vector by its w component,
+// turning clip space coordinates into normalized device coordinates, and
+// returns itself.
+func (v *V4) PerspectiveDivide() *V4 {
+	if v.w != 0 {
+		v.x, v.y, v.z, v.w = v.x / v.w, v.y / v.w, v.z / v.w, 1
+	}
+	return v
+}
+
 func Cross(v1 V4, v2 V4) V4 {
 	// Computes the cross product of the specified matrices.
 	return V4{
@@ -204,16 +215,132 @@ func (m *M4) Inverse() *M4 {
     }
 }
 
+// Quat represents a rotation quaternion w + xi + yj + zk.
+type Quat struct {
+	w, x, y, z float64
+}
+
+// NewQuat returns a unit quaternion that rotates about the specified axis by
+// the specified angle in radians.
+func NewQuat(axis *V4, angle float64) *Quat {
+	l := axis.Length()
+	if l <= 0 {
+		panic("Cannot rotate around vector of length zero")
+	}
+	s := math.Sin(angle / 2) / l
+	return &Quat{w: math.Cos(angle / 2), x: axis.x * s, y: axis.y * s, z: axis.z * s}
+}
+
+// NewQuatEuler returns a quaternion that rotates along the specified angles
+// (in radians), equivalent to RotX(ax).Mul(RotY(ay)).Mul(RotZ(az)).
+func NewQuatEuler(ax float64, ay float64, az float64) *Quat {
+	return NewQuat(NewV4(1, 0, 0), ax).Mul(NewQuat(NewV4(0, 1, 0), ay)).Mul(NewQuat(NewV4(0, 0, 1), az))
+}
+
+// QuatFromM4 returns the quaternion of the rotation in the upper 3x3 of the
+// specified matrix, which must be orthonormal.
+func QuatFromM4(m *M4) *Quat {
+	// https://www.euclideanspace.com/maths/geometry/rotations/conversions/matrixToQuaternion/
+	if tr := m.a0 + m.b1 + m.c2; tr > 0 {
+		s := math.Sqrt(tr + 1) * 2
+		return &Quat{w: s / 4, x: (m.c1 - m.b2) / s, y: (m.a2 - m.c0) / s, z: (m.b0 - m.a1) / s}
+	} else if m.a0 > m.b1 && m.a0 > m.c2 {
+		s := math.Sqrt(1 + m.a0 - m.b1 - m.c2) * 2
+		return &Quat{w: (m.c1 - m.b2) / s, x: s / 4, y: (m.a1 + m.b0) / s, z: (m.a2 + m.c0) / s}
+	} else if m.b1 > m.c2 {
+		s := math.Sqrt(1 + m.b1 - m.a0 - m.c2) * 2
+		return &Quat{w: (m.a2 - m.c0) / s, x: (m.a1 + m.b0) / s, y: s / 4, z: (m.b2 + m.c1) / s}
+	}
+	s := math.Sqrt(1 + m.c2 - m.a0 - m.b1) * 2
+	return &Quat{w: (m.b0 - m.a1) / s, x: (m.a2 + m.c0) / s, y: (m.b2 + m.c1) / s, z: s / 4}
+}
+
+// Mul multiplies this quaternion with the specified quaternion and returns
+// itself. Like with matrices, the resulting rotation applies q2 first.
+func (q *Quat) Mul(q2 *Quat) *Quat {
+	q.w, q.x, q.y, q.z =
+		q.w * q2.w - q.x * q2.x - q.y * q2.y - q.z * q2.z,
+		q.w * q2.x + q.x * q2.w + q.y * q2.z - q.z * q2.y,
+		q.w * q2.y - q.x * q2.z + q.y * q2.w + q.z * q2.x,
+		q.w * q2.z + q.x * q2.y - q.y * q2.x + q.z * q2.w
+	return q
+}
+
+// Length returns the norm of this quaternion.
+func (q *Quat) Length() float64 {
+	return math.Sqrt(q.w * q.w + q.x * q.x + q.y * q.y + q.z * q.z)
+}
+
+// Normalize normalizes this quaternion to unit length and returns itself.
+func (q *Quat) Normalize() *Quat {
+	l := q.Length()
+	if l > 0 {
+		q.w /= l
+		q.x /= l
+		q.y /= l
+		q.z /= l
+	}
+	return q
+}
+
+// Conjugate returns a new quaternion containing the conjugate of this
+// quaternion, which for unit quaternions is the inverse rotation.
+func (q *Quat) Conjugate() *Quat {
+	return &Quat{w: q.w, x: -q.x, y: -q.y, z: -q.z}
+}
+
+// M4 returns a new rotation matrix equivalent to this unit quaternion.
+func (q *Quat) M4() *M4 {
+	xx, yy, zz := q.x * q.x, q.y * q.y, q.z * q.z
+	xy, xz, yz := q.x * q.y, q.x * q.z, q.y * q.z
+	wx, wy, wz := q.w * q.x, q.w * q.y, q.w * q.z
+
+	return &M4{
+		1 - 2 * (yy + zz), 2 * (xy - wz), 2 * (xz + wy), 0,
+		2 * (xy + wz), 1 - 2 * (xx + zz), 2 * (yz - wx), 0,
+		2 * (xz - wy), 2 * (yz + wx), 1 - 2 * (xx + yy), 0,
+		0, 0, 0, 1}
+}
+
+// Slerp returns a new quaternion that spherically interpolates between the
+// specified unit quaternions along the shortest arc, where t=0 yields q1
+// and t=1 yields q2.
+func Slerp(q1 *Quat, q2 *Quat, t float64) *Quat {
+	end := *q2
+	dot := q1.w * end.w + q1.x * end.x + q1.y * end.y + q1.z * end.z
+	if dot < 0 {
+		// q and -q are the same rotation; take the shortest path:
+		end = Quat{-end.w, -end.x, -end.y, -end.z}
+		dot = -dot
+	}
+
+	s1, s2 := 1 - t, t
+	if dot < .9995 {
+		// otherwise the quaternions are so close that linear interpolation
+		// is accurate, while sin(theta) approaches zero
+		theta := math.Acos(dot)
+		s1 = math.Sin((1 - t) * theta) / math.Sin(theta)
+		s2 = math.Sin(t * theta) / math.Sin(theta)
+	}
+	return (&Quat{
+		w: s1 * q1.w + s2 * end.w,
+		x: s1 * q1.x + s2 * end.x,
+		y: s1 * q1.y + s2 * end.y,
+		z: s1 * q1.z + s2 * end.z,
+	}).Normalize()
+}
+
 type Triangle struct {
 	// A single triangle, consisting of 3 vertices.
 	v1, v2, v3 V4
+	colors *[3]color.NRGBA	// optional colors of the vertices, shared by clones
 }
 
 func NewTriangle(points ...float64) *Triangle {
 	return &Triangle{
-		*NewV4(points[0], points[1], points[2]),
-		*NewV4(points[3], points[4], points[5]),
-		*NewV4(points[6], points[7], points[8])}
+		v1: *NewV4(points[0], points[1], points[2]),
+		v2: *NewV4(points[3], points[4], points[5]),
+		v3: *NewV4(points[6], points[7], points[8])}
 }
 
 func (t Triangle) Clone() *Triangle {
@@ -332,6 +459,43 @@ func RotZ(a float64) *M4 {
 		0, 0, 0, 1}
 }
 
+// PerspectiveM creates a new perspective projection matrix that maps the
+// view frustum of a camera looking down the negative z-axis into clip
+// space, where after perspective division, the frustum occupies the
+// ((-1, -1, -1), (1, 1, 1)) cube. `fovy` is the vertical angle of view in
+// radians, `aspect` the ratio of width to height and `near` and `far` the
+// (positive) distances to the clipping planes.
+func PerspectiveM(fovy float64, aspect float64, near float64, far float64) *M4 {
+	f := 1 / math.Tan(fovy / 2)
+	return &M4{
+		f / aspect, 0, 0, 0,
+		0, f, 0, 0,
+		0, 0, (far + near) / (near - far), 2 * far * near / (near - far),
+		0, 0, -1, 0}
+}
+
+// OrthoM creates a new orthographic projection matrix that maps the
+// specified box of a camera looking down the negative z-axis into the
+// ((-1, -1, -1), (1, 1, 1)) clip space cube.
+func OrthoM(left float64, right float64, bottom float64, top float64, near float64, far float64) *M4 {
+	return &M4{
+		2 / (right - left), 0, 0, -(right + left) / (right - left),
+		0, 2 / (top - bottom), 0, -(top + bottom) / (top - bottom),
+		0, 0, -2 / (far - near), -(far + near) / (far - near),
+		0, 0, 0, 1}
+}
+
+// ViewportM creates a new viewport matrix that maps normalized device
+// coordinates onto a raster of the specified size. Depth is mapped from the
+// near plane at 1 to the far plane at 0, so that nearer is larger.
+func ViewportM(width float64, height float64) *M4 {
+	return &M4{
+		width / 2, 0, 0, width / 2,
+		0, height / 2, 0, height / 2,
+		0, 0, -.5, .5,
+		0, 0, 0, 1}
+}
+
 // Rot returns a rotation matrix that when applied to a vector, rotates the
 // vector about the line through point `p` with direction vector `v` by the
 // specified angle in radians.
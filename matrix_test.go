@@ -246,3 +246,87 @@ func TestRot(t *testing.T) {
             Rot(NewV4(.5, .7, -1.1), &V4{-1, -.8, 12, 1}, rad(90))))
 
 }
+
+func TestQuat_M4(t *testing.T) {
+	assertAlmostEqualM4(t, new(M4).SetIdentity(), (&Quat{w: 1}).M4(), 1e-6)
+	assertAlmostEqualM4(t, RotX(rad(30)), NewQuat(NewV4(1, 0, 0), rad(30)).M4(), 1e-6)
+	assertAlmostEqualM4(t, RotY(rad(-75)), NewQuat(NewV4(0, 2, 0), rad(-75)).M4(), 1e-6)
+	assertAlmostEqualM4(t, RotZ(rad(190)), NewQuat(NewV4(0, 0, 1), rad(190)).M4(), 1e-6)
+
+	axis := NewV4(-1, -.8, 12)
+	assertAlmostEqualM4(t, Rot(NewV4(0, 0, 0), axis, rad(70)), NewQuat(axis, rad(70)).M4(), 1e-6)
+
+	assertAlmostEqualM4(t,
+		RotX(rad(90)).Mul(RotY(rad(23.4))).Mul(RotZ(rad(-40))),
+		NewQuatEuler(rad(90), rad(23.4), rad(-40)).M4(), 1e-6)
+}
+
+func TestQuatFromM4(t *testing.T) {
+	for _, m := range []*M4{
+		new(M4).SetIdentity(),
+		RotX(rad(179)),
+		RotY(rad(179)),
+		RotZ(rad(179)),
+		RotX(rad(-23)).Mul(RotY(rad(2))).Mul(RotZ(rad(140))),
+	} {
+		assertAlmostEqualM4(t, m, QuatFromM4(m).M4(), 1e-6)
+	}
+}
+
+func TestQuat_Mul(t *testing.T) {
+	q := NewQuat(NewV4(1, 0, 0), rad(30))
+	q.Mul(NewQuat(NewV4(0, 1, 0), rad(60)))
+	assertAlmostEqualM4(t, RotX(rad(30)).Mul(RotY(rad(60))), q.M4(), 1e-6)
+
+	// multiplying with the conjugate yields the identity:
+	assertAlmostEqualM4(t, new(M4).SetIdentity(), q.Conjugate().Mul(q).M4(), 1e-6)
+
+	assert.InDelta(t, 1., (&Quat{1, 2, 3, 4}).Normalize().Length(), 1e-6)
+}
+
+func TestSlerp(t *testing.T) {
+	q1 := NewQuat(NewV4(0, 0, 1), rad(10))
+	q2 := NewQuat(NewV4(0, 0, 1), rad(100))
+
+	assertAlmostEqualM4(t, q1.M4(), Slerp(q1, q2, 0).M4(), 1e-6)
+	assertAlmostEqualM4(t, q2.M4(), Slerp(q1, q2, 1).M4(), 1e-6)
+	assertAlmostEqualM4(t, RotZ(rad(32.5)), Slerp(q1, q2, .25).M4(), 1e-6)
+	assertAlmostEqualM4(t, RotZ(rad(55)), Slerp(q1, q2, .5).M4(), 1e-6)
+
+	// takes the shortest path from 10 to 350 degrees, through 0:
+	q3 := NewQuat(NewV4(0, 0, 1), rad(350))
+	assertAlmostEqualM4(t, RotZ(0), Slerp(q1, q3, .5).M4(), 1e-6)
+
+	// nearly identical rotations:
+	q4 := NewQuat(NewV4(0, 0, 1), rad(10.001))
+	assertAlmostEqualM4(t, RotZ(rad(10.0005)), Slerp(q1, q4, .5).M4(), 1e-6)
+}
+
+func TestPerspectiveM(t *testing.T) {
+	m := PerspectiveM(rad(90), 2, 1, 10)
+
+	// the near and far planes map to -1 and 1 in NDC:
+	assertAlmostEqualV4(t, *NewV4(0, 0, -1), *NewV4(0, 0, -1).MultiplyM(m).PerspectiveDivide())
+	assertAlmostEqualV4(t, *NewV4(0, 0, 1), *NewV4(0, 0, -10).MultiplyM(m).PerspectiveDivide())
+
+	// the corners of the frustum map to the corners of the NDC cube:
+	assertAlmostEqualV4(t, *NewV4(1, 1, -1), *NewV4(2, 1, -1).MultiplyM(m).PerspectiveDivide())
+	assertAlmostEqualV4(t, *NewV4(-1, -1, 1), *NewV4(-20, -10, -10).MultiplyM(m).PerspectiveDivide())
+
+	// clip space w holds the distance along the view direction:
+	assert.InDelta(t, 5., NewV4(1, 2, -5).MultiplyM(m).w, 1e-6)
+}
+
+func TestOrthoM(t *testing.T) {
+	m := OrthoM(-2, 2, -1, 3, 1, 10)
+	assertAlmostEqualV4(t, *NewV4(-1, -1, -1), *NewV4(-2, -1, -1).MultiplyM(m))
+	assertAlmostEqualV4(t, *NewV4(1, 1, 1), *NewV4(2, 3, -10).MultiplyM(m))
+	assertAlmostEqualV4(t, *NewV4(0, 0, 0), *NewV4(0, 1, -5.5).MultiplyM(m))
+}
+
+func TestViewportM(t *testing.T) {
+	m := ViewportM(200, 100)
+	assertAlmostEqualV4(t, *NewV4(0, 0, 1), *NewV4(-1, -1, -1).MultiplyM(m))
+	assertAlmostEqualV4(t, *NewV4(200, 100, 0), *NewV4(1, 1, 1).MultiplyM(m))
+	assertAlmostEqualV4(t, *NewV4(100, 50, .5), *NewV4(0, 0, 0).MultiplyM(m))
+}
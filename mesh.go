@@ -0,0 +1,217 @@
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"image/color"
+	"math"
+	"sort"
+)
+
+// Mesh is an indexed triangle mesh, in which triangles share their
+// vertices. Unlike Model, which stores three copies of every vertex, this
+// makes connectivity explicit and transforms each vertex only once.
+type Mesh struct {
+	vertices []V4
+	faces    [][3]int	// vertex indices, in the winding order of the triangles
+}
+
+// weldTolerance is the distance, relative to the size of a model, within
+// which vertices are considered shared.
+const weldTolerance = 1e-9
+
+// Edge is an undirected edge between two mesh vertices, identified by
+// their indices with a < b.
+type Edge struct {
+	a, b int
+}
+
+func newEdge(a int, b int) Edge {
+	if a > b {
+		a, b = b, a
+	}
+	return Edge{a, b}
+}
+
+// NewMesh converts the model into a mesh, welding vertices that are within
+// `tolerance` distance of a previously seen vertex onto that vertex. Use a
+// tolerance of zero to only weld identical vertices. Triangles that
+// collapse because two of their vertices are welded are dropped.
+func NewMesh(model *Model, tolerance float64) *Mesh {
+	type cell [3]int64
+	var cellOf = func(v V4) cell {
+		if tolerance <= 0 {
+			return cell{
+				int64(math.Float64bits(v.x)),
+				int64(math.Float64bits(v.y)),
+				int64(math.Float64bits(v.z))}
+		}
+		return cell{
+			int64(math.Floor(v.x / tolerance)),
+			int64(math.Floor(v.y / tolerance)),
+			int64(math.Floor(v.z / tolerance))}
+	}
+
+	// a spatial hash of cells of the size of the tolerance, so that only the
+	// surrounding cells need to be searched for vertices to weld onto
+	grid := make(map[cell][]int)
+	mesh := &Mesh{}
+	var index = func(v V4) int {
+		c := cellOf(v)
+		if tolerance <= 0 {
+			if i, found := grid[c]; found {
+				return i[0]
+			}
+		} else {
+			for dx := int64(-1); dx <= 1; dx++ {
+				for dy := int64(-1); dy <= 1; dy++ {
+					for dz := int64(-1); dz <= 1; dz++ {
+						for _, i := range grid[cell{c[0] + dx, c[1] + dy, c[2] + dz}] {
+							d := v.Subtract(mesh.vertices[i])
+							if d.Length() <= tolerance {
+								return i
+							}
+						}
+					}
+				}
+			}
+		}
+		mesh.vertices = append(mesh.vertices, v)
+		grid[c] = append(grid[c], len(mesh.vertices) - 1)
+		return len(mesh.vertices) - 1
+	}
+
+	for _, t := range model.triangles {
+		f := [3]int{index(t.v1), index(t.v2), index(t.v3)}
+		if f[0] != f[1] && f[1] != f[2] && f[2] != f[0] {
+			mesh.faces = append(mesh.faces, f)
+		}
+	}
+	return mesh
+}
+
+// weld converts the model into a mesh, welding vertices within a distance
+// relative to the model's size, which absorbs the rounding errors of
+// transformed models.
+func weld(model *Model) *Mesh {
+	size := model.Bounds().Size()
+	return NewMesh(model, weldTolerance * math.Max(size.x, math.Max(size.y, size.z)))
+}
+
+// Model converts the mesh back into a model.
+func (m *Mesh) Model() *Model {
+	model := Model{make([]Triangle, len(m.faces))}
+	for i, f := range m.faces {
+		model.triangles[i] = Triangle{v1: m.vertices[f[0]], v2: m.vertices[f[1]], v3: m.vertices[f[2]]}
+	}
+	return &model
+}
+
+func (m Mesh) Clone() *Mesh {
+	return &Mesh{
+		vertices: append([]V4(nil), m.vertices...),
+		faces:    append([][3]int(nil), m.faces...),
+	}
+}
+
+// Apply applies the specified transformation matrix to each of the mesh's
+// vertices and returns itself.
+func (m *Mesh) Apply(mat *M4) *Mesh {
+	for i := range m.vertices {
+		m.vertices[i].MultiplyM(mat)
+	}
+	return m
+}
+
+// Edges returns the mesh's unique edges, ordered by vertex index.
+func (m *Mesh) Edges() []Edge {
+	faces := m.EdgeFaces()
+	edges := make([]Edge, 0, len(faces))
+	for e := range faces {
+		edges = append(edges, e)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		return edges[i].a < edges[j].a || (edges[i].a == edges[j].a && edges[i].b < edges[j].b)
+	})
+	return edges
+}
+
+// EdgeFaces returns the indices of the faces adjacent to each edge. In a
+// closed, manifold mesh, every edge has exactly two faces, while boundary
+// edges have one.
+func (m *Mesh) EdgeFaces() map[Edge][]int {
+	faces := make(map[Edge][]int)
+	for i, f := range m.faces {
+		for j := 0; j < 3; j++ {
+			e := newEdge(f[j], f[(j + 1) % 3])
+			faces[e] = append(faces[e], i)
+		}
+	}
+	return faces
+}
+
+// Neighbors returns the indices of the faces that share an edge with the
+// specified face, looked up in `adjacent`, the mesh's EdgeFaces. Building
+// that map once keeps visiting the neighbors of every face linear.
+func (m *Mesh) Neighbors(adjacent map[Edge][]int, face int) []int {
+	var neighbors []int
+	f := m.faces[face]
+	for j := 0; j < 3; j++ {
+		for _, n := range adjacent[newEdge(f[j], f[(j + 1) % 3])] {
+			if n != face {
+				neighbors = append(neighbors, n)
+			}
+		}
+	}
+	return neighbors
+}
+
+// coloredVertex is the position and color of a vertex.
+type coloredVertex struct {
+	position V4
+	color    color.NRGBA
+}
+
+// indexColored lists the distinct vertices of the model's triangles and
+// returns the triangles as indices into that list, like an exact NewMesh
+// that also keeps vertex colors. Vertices are only shared by triangles that
+// agree on their color. When any of the triangles has colors, triangles
+// without colors are white and `colored` is true.
+func indexColored(model *Model) (vertices []coloredVertex, faces [][3]int, colored bool) {
+	for i := range model.triangles {
+		colored = colored || model.triangles[i].colors != nil
+	}
+
+	indices := make(map[coloredVertex]int)
+	faces = make([][3]int, len(model.triangles))
+	for i := range model.triangles {
+		t := &model.triangles[i]
+		for j, position := range []V4{t.v1, t.v2, t.v3} {
+			v := coloredVertex{position: position}
+			if t.colors != nil {
+				v.color = t.colors[j]
+			} else if colored {
+				v.color = color.NRGBA{255, 255, 255, 255}
+			}
+			index, found := indices[v]
+			if !found {
+				index = len(vertices)
+				indices[v] = index
+				vertices = append(vertices, v)
+			}
+			faces[i][j] = index
+		}
+	}
+	return vertices, faces, colored
+}
@@ -0,0 +1,83 @@
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMesh(t *testing.T) {
+	mesh := NewMesh(Cube(), 1e-9)
+	assert.Len(t, mesh.vertices, 8)
+	assert.Len(t, mesh.faces, 12)
+
+	// converting back preserves the triangles and their winding:
+	model := mesh.Model()
+	assert.Len(t, model.triangles, 12)
+	for i, tr := range Cube().triangles {
+		assertAlmostEqualV4(t, tr.v1, model.triangles[i].v1)
+		assertAlmostEqualV4(t, tr.v2, model.triangles[i].v2)
+		assertAlmostEqualV4(t, tr.v3, model.triangles[i].v3)
+	}
+
+	// the rotated faces of the cube carry rounding errors, so that without
+	// tolerance, not all corners are welded:
+	assert.True(t, len(NewMesh(Cube(), 0).vertices) > 8)
+}
+
+func TestNewMesh_Tolerance(t *testing.T) {
+	model := &Model{[]Triangle{
+		*NewTriangle(0, 0, 0,  1, 0, 0,  0, 1, 0),
+		*NewTriangle(1.0001, 0, 0,  1, 1, 0,  0, 1.0001, 0),
+		*NewTriangle(0, 0, 0,  .00005, 0, 0,  0, 0, 1),	// collapses when welded
+	}}
+
+	mesh := NewMesh(model, 1e-3)
+	assert.Equal(t, []V4{*NewV4(0, 0, 0), *NewV4(1, 0, 0), *NewV4(0, 1, 0), *NewV4(1, 1, 0), *NewV4(0, 0, 1)}, mesh.vertices)
+	assert.Equal(t, [][3]int{{0, 1, 2}, {1, 3, 2}}, mesh.faces)
+
+	assert.Len(t, NewMesh(model, 1e-5).vertices, 8)
+	assert.Len(t, NewMesh(model, 1e-5).faces, 3)
+}
+
+func TestMesh_Apply(t *testing.T) {
+	mesh := NewMesh(Cube(), 1e-9)
+	clone := mesh.Clone().Apply(TransM(NewV4(1, 2, 3)))
+	assertAlmostEqualV4(t, *NewV4(1, 2, 3), clone.Model().Bounds().Center())
+	assertAlmostEqualV4(t, *NewV4(0, 0, 0), mesh.Model().Bounds().Center())
+}
+
+func TestMesh_Edges(t *testing.T) {
+	mesh := NewMesh(Cube(), 1e-9)
+
+	// 12 edges of the cube, plus a diagonal on each of its 6 faces:
+	edges := mesh.Edges()
+	assert.Len(t, edges, 18)
+	for e, faces := range mesh.EdgeFaces() {
+		assert.True(t, e.a < e.b)
+		assert.Len(t, faces, 2, "edge %v", e)
+	}
+
+	// each triangle borders its twin on the same side, and 2 others:
+	adjacent := mesh.EdgeFaces()
+	for i := range mesh.faces {
+		assert.Len(t, mesh.Neighbors(adjacent, i), 3)
+	}
+
+	// a lone triangle only has boundary edges:
+	mesh = NewMesh(&Model{[]Triangle{*NewTriangle(0, 0, 0,  1, 0, 0,  0, 1, 0)}}, 0)
+	assert.Equal(t, []Edge{{0, 1}, {0, 2}, {1, 2}}, mesh.Edges())
+	assert.Empty(t, mesh.Neighbors(mesh.EdgeFaces(), 0))
+}
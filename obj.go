@@ -0,0 +1,318 @@
+// A Wavefront OBJ reader and writer for polygonal geometry.
+// https://en.wikipedia.org/wiki/Wavefront_.obj_file
+//
+// Limitations: only reads vertex positions, the vertex colors some tools add
+// to them, and polygonal faces. Texture coordinates, normals, materials,
+// lines and free-form geometry are ignored.
+//
+//
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// OBJReader reads the faces of OBJ files as triangles, splitting polygons.
+type OBJReader struct {
+	scanner   *bufio.Scanner
+	line      int	// current line number
+	text      string	// current line, joined with its continuation lines
+	vertices  []V4
+	vcolors   []color.NRGBA	// of the vertices, white for those without
+	colored   bool	// whether any of the vertices has a color
+	triangles []Triangle	// triangles of the last face not yet returned
+}
+
+// NewOBJReader returns a reader for OBJ data.
+func NewOBJReader(reader io.Reader) *OBJReader {
+	return &OBJReader{scanner: bufio.NewScanner(reader)}
+}
+
+func (r *OBJReader) errorf(format string, args ...interface{}) error {
+	return &ParseError{
+		Format: "obj",
+		Line:   r.line,
+		Text:   strings.TrimSpace(r.text),
+		Msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+// nextLine returns the whitespace separated fields of the next statement,
+// skipping blank lines and comments and joining lines that end with a
+// backslash. It returns nil when the end of the stream is reached.
+func (r *OBJReader) nextLine() ([]string, error) {
+	r.text = ""
+	for r.scanner.Scan() {
+		r.line++
+		text := r.scanner.Text()
+		if strings.HasSuffix(text, "\\") {
+			r.text += strings.TrimSuffix(text, "\\") + " "
+			continue
+		}
+		r.text += text
+		if i := strings.IndexByte(r.text, '#'); i >= 0 {
+			r.text = r.text[:i]
+		}
+		if fields := strings.Fields(r.text); len(fields) > 0 {
+			return fields, nil
+		}
+		r.text = ""
+	}
+	return nil, r.scanner.Err()
+}
+
+// parseVertex parses the x, y and z coordinates of a "v" statement and
+// the optional weight, which only applies to rational curves and is ignored.
+// Instead of the weight, the coordinates may be followed by the red, green,
+// blue and optional alpha components of the vertex color, from 0 to 1.
+func (r *OBJReader) parseVertex(fields []string) (*V4, *color.NRGBA, error) {
+	if len(fields) < 3 || len(fields) > 7 || len(fields) == 5 {
+		return nil, nil, r.errorf("expected 3 or 4 coordinates or 3 coordinates and a color, got %d values", len(fields))
+	}
+	values := make([]float64, len(fields))
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return nil, nil, r.errorf("invalid coordinate %q", f)
+		}
+		values[i] = v
+	}
+	v := NewV4(values[0], values[1], values[2])
+	if len(values) < 6 {
+		return v, nil, nil
+	}
+	components := [4]uint8{3: 255}
+	for i, c := range values[3:] {
+		components[i] = uint8(math.Max(0, math.Min(255, math.Round(c * 255))))
+	}
+	return v, &color.NRGBA{components[0], components[1], components[2], components[3]}, nil
+}
+
+// parseFace returns the vertex indices of an "f" statement, whose
+// references are of the forms v, v/vt, v//vn or v/vt/vn. Negative indices
+// count back from the last vertex read.
+func (r *OBJReader) parseFace(fields []string) ([]int, error) {
+	if len(fields) < 3 {
+		return nil, r.errorf("expected at least 3 vertices, got %d", len(fields))
+	}
+	indices := make([]int, len(fields))
+	for i, f := range fields {
+		ref := strings.SplitN(f, "/", 2)[0]
+		index, err := strconv.Atoi(ref)
+		if err != nil {
+			return nil, r.errorf("invalid vertex reference %q", f)
+		}
+		if index < 0 {
+			index += len(r.vertices) + 1
+		}
+		if index < 1 || index > len(r.vertices) {
+			return nil, r.errorf("vertex %s out of range", ref)
+		}
+		indices[i] = index - 1
+	}
+	return indices, nil
+}
+
+// ReadTriangle returns the next triangle from the stream. Polygons are
+// triangulated with ear clipping, so that they may also be concave. When
+// any of the vertices has a color, triangles carry the colors of their
+// vertices, which are white for vertices without. When the end of the file
+// is reached, io.EOF is returned. Malformed statements produce a
+// *ParseError.
+func (r *OBJReader) ReadTriangle() (*Triangle, error) {
+	for len(r.triangles) == 0 {
+		fields, err := r.nextLine()
+		if err != nil {
+			return nil, err
+		}
+		if fields == nil {
+			return nil, io.EOF
+		}
+		switch fields[0] {
+		case "v":
+			v, c, err := r.parseVertex(fields[1:])
+			if err != nil {
+				return nil, err
+			}
+			r.vertices = append(r.vertices, *v)
+			if c == nil {
+				c = &color.NRGBA{255, 255, 255, 255}
+			}
+			r.vcolors = append(r.vcolors, *c)
+			r.colored = r.colored || len(fields) > 5
+		case "f", "fo":
+			indices, err := r.parseFace(fields[1:])
+			if err != nil {
+				return nil, err
+			}
+			polygon := make([]V4, len(indices))
+			for i, index := range indices {
+				polygon[i] = r.vertices[index]
+			}
+			for _, t := range triangulate(polygon) {
+				triangle := Triangle{v1: polygon[t[0]], v2: polygon[t[1]], v3: polygon[t[2]]}
+				if r.colored {
+					triangle.colors = &[3]color.NRGBA{
+						r.vcolors[indices[t[0]]], r.vcolors[indices[t[1]]], r.vcolors[indices[t[2]]]}
+				}
+				r.triangles = append(r.triangles, triangle)
+			}
+		}
+		// other statements, like groups (g), objects (o), smoothing groups
+		// (s), materials and texture coordinates, don't affect the shape.
+	}
+	t := r.triangles[0]
+	r.triangles = r.triangles[1:]
+	return &t, nil
+}
+
+// ReadModel returns the model as defined in the loaded OBJ file, which is
+// optionally scaled to fit in the unit cube like STLReader.ReadModel.
+func (r *OBJReader) ReadModel(scale bool) (*Model, error) {
+	return readModel(r, scale)
+}
+
+// triangulate splits the polygon into triangles of the same winding using
+// ear clipping in the polygon's plane, and returns their vertex indices.
+// Degenerate polygons, for which no ears can be found, are split into a
+// triangle fan instead.
+func triangulate(polygon []V4) [][3]int {
+	if len(polygon) == 3 {
+		return [][3]int{{0, 1, 2}}
+	}
+
+	// projects the polygon onto the axis plane its normal is closest to,
+	// oriented so that the polygon winds counter-clockwise:
+	var n V4
+	for i, v := range polygon {
+		u := polygon[(i + 1) % len(polygon)]
+		n.x += (v.y - u.y) * (v.z + u.z)
+		n.y += (v.z - u.z) * (v.x + u.x)
+		n.z += (v.x - u.x) * (v.y + u.y)
+	}
+	points := make([]V2, len(polygon))
+	for i, v := range polygon {
+		switch ax, ay, az := math.Abs(n.x), math.Abs(n.y), math.Abs(n.z); {
+		case az >= ax && az >= ay:
+			points[i] = V2{v.x, math.Copysign(1, n.z) * v.y}
+		case ax >= ay:
+			points[i] = V2{v.y, math.Copysign(1, n.x) * v.z}
+		default:
+			points[i] = V2{v.z, math.Copysign(1, n.y) * v.x}
+		}
+	}
+
+	cross := func(a, b, c V2) float64 {
+		return (b.x - a.x) * (c.y - a.y) - (b.y - a.y) * (c.x - a.x)
+	}
+	var triangles [][3]int
+	remaining := make([]int, len(polygon))
+	for i := range remaining {
+		remaining[i] = i
+	}
+
+	for len(remaining) > 3 {
+		ear := -1
+		for i := 0; i < len(remaining) && ear < 0; i++ {
+			a := points[remaining[(i + len(remaining) - 1) % len(remaining)]]
+			b := points[remaining[i]]
+			c := points[remaining[(i + 1) % len(remaining)]]
+			if cross(a, b, c) <= 0 {
+				continue	// reflex or degenerate corner
+			}
+			ear = i
+			for _, j := range remaining {
+				p := points[j]
+				if p == a || p == b || p == c {
+					continue
+				}
+				if cross(a, b, p) >= 0 && cross(b, c, p) >= 0 && cross(c, a, p) >= 0 {
+					ear = -1	// another vertex lies inside
+					break
+				}
+			}
+		}
+		if ear < 0 {
+			for i := 1; i + 1 < len(remaining); i++ {
+				triangles = append(triangles, [3]int{remaining[0], remaining[i], remaining[i + 1]})
+			}
+			return triangles
+		}
+		prev := remaining[(ear + len(remaining) - 1) % len(remaining)]
+		next := remaining[(ear + 1) % len(remaining)]
+		triangles = append(triangles, [3]int{prev, remaining[ear], next})
+		remaining = append(remaining[:ear], remaining[ear + 1:]...)
+	}
+	return append(triangles, [3]int{remaining[0], remaining[1], remaining[2]})
+}
+
+// OBJWriter serializes models as OBJ files, in which triangles share their
+// vertices.
+type OBJWriter struct {
+	writer  io.Writer
+	normals bool
+}
+
+// NewOBJWriter returns a writer that also writes the face normals of the
+// triangles when `normals` is true.
+func NewOBJWriter(writer io.Writer, normals bool) *OBJWriter {
+	return &OBJWriter{writer: writer, normals: normals}
+}
+
+// WriteModel writes the model's vertices, welded like those of wireframes,
+// followed by its faces. Triangles that collapse to a line or point when
+// welding are left out.
+func (w *OBJWriter) WriteModel(model *Model) error {
+	mesh := weld(model)
+	out := bufio.NewWriter(w.writer)
+	fmt.Fprintln(out, "# written by 3dgo")
+	fmt.Fprintf(out, "# %d vertices, %d faces\n", len(mesh.vertices), len(mesh.faces))
+	for _, v := range mesh.vertices {
+		fmt.Fprintf(out, "v %g %g %g\n", v.x, v.y, v.z)
+	}
+
+	if !w.normals {
+		for _, f := range mesh.faces {
+			fmt.Fprintf(out, "f %d %d %d\n", f[0] + 1, f[1] + 1, f[2] + 1)
+		}
+		return out.Flush()
+	}
+
+	// faces share identical normals, like those of the triangles of quads:
+	normals := make(map[V4]int)
+	faces := make([]int, len(mesh.faces))
+	for i, f := range mesh.faces {
+		n := facetNormal(&Triangle{v1: mesh.vertices[f[0]], v2: mesh.vertices[f[1]], v3: mesh.vertices[f[2]]})
+		index, found := normals[n]
+		if !found {
+			index = len(normals) + 1
+			normals[n] = index
+			fmt.Fprintf(out, "vn %g %g %g\n", n.x, n.y, n.z)
+		}
+		faces[i] = index
+	}
+	for i, f := range mesh.faces {
+		fmt.Fprintf(out, "f %d//%d %d//%d %d//%d\n",
+			f[0] + 1, faces[i], f[1] + 1, faces[i], f[2] + 1, faces[i])
+	}
+	return out.Flush()
+}
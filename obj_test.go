@@ -0,0 +1,184 @@
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func ExampleOBJReader() {
+	var obj = `# a unit square in two groups
+o square
+v 0 0 0
+v 1 0 0
+v 1 1 0
+v 0 1 0 \
+  1
+g first
+f 1/1/1 2/2/1 3//1
+g second
+f -4 -2 -1
+`
+
+	reader := NewOBJReader(strings.NewReader(obj))
+	fmt.Println(reader.ReadTriangle())
+	fmt.Println(reader.ReadTriangle())
+	fmt.Println(reader.ReadTriangle())
+
+	// Output:
+	// &{{0 0 0 1} {1 0 0 1} {1 1 0 1} <nil>} <nil>
+	// &{{0 0 0 1} {1 1 0 1} {0 1 0 1} <nil>} <nil>
+	// <nil> EOF
+}
+
+// area returns the total area of the model's triangles.
+func area(model *Model) float64 {
+	a := 0.
+	for i := range model.triangles {
+		n := model.triangles[i].Normal()
+		a += n.Length() / 2
+	}
+	return a
+}
+
+func TestOBJReader_Polygons(t *testing.T) {
+	// a concave L-shape, standing up in the xz-plane, and a pentagon:
+	var obj = `
+v 0 0 0
+v 2 0 0
+v 2 0 1
+v 1 0 1
+v 1 0 2
+v 0 0 2
+f 1 6 5 4 3 2
+v 0 0 5
+v 1 0 5
+v 1.5 1 5
+v .5 2 5
+v -.5 1 5
+f 7 8 9 10 11
+`
+	model, err := NewOBJReader(strings.NewReader(obj)).ReadModel(false)
+	assert.NoError(t, err)
+	assert.Len(t, model.triangles, 7)
+
+	// triangles cover the polygon exactly and keep its winding:
+	lshape := &Model{model.triangles[:4]}
+	assert.InDelta(t, 3, area(lshape), 1e-9)
+	for i := range lshape.triangles {
+		n := lshape.triangles[i].Normal()
+		assertAlmostEqualV4(t, *NewV4(0, 1, 0), *n.Normalize())
+	}
+	for i := 4; i < 7; i++ {
+		n := model.triangles[i].Normal()
+		assertAlmostEqualV4(t, *NewV4(0, 0, 1), *n.Normalize())
+	}
+
+	// scaling fits the model in the unit cube:
+	model, err = NewOBJReader(strings.NewReader(obj)).ReadModel(true)
+	assert.NoError(t, err)
+	b := model.Bounds()
+	assert.InDelta(t, 1, math.Max(b.Size().x, math.Max(b.Size().y, b.Size().z)), 1e-9)
+}
+
+func TestOBJReader_Colors(t *testing.T) {
+	// weights don't move vertices, and vertices may have colors:
+	var obj = `
+v 0 0 0 2
+v 1 0 0 1 0 0
+v 1 1 0 0 .5 1 .5
+f 1 2 3
+`
+	model, err := NewOBJReader(strings.NewReader(obj)).ReadModel(false)
+	assert.NoError(t, err)
+	assert.Equal(t, *NewV4(0, 0, 0), model.triangles[0].v1)
+	assert.Equal(t, *NewV4(1, 1, 0), model.triangles[0].v3)
+	assert.Equal(t, &[3]color.NRGBA{{255, 255, 255, 255}, {255, 0, 0, 255}, {0, 128, 255, 128}}, model.triangles[0].colors)
+
+	// without colors, triangles have none:
+	model, err = NewOBJReader(strings.NewReader("v 0 0 0 2\nv 1 0 0\nv 1 1 0\nf 1 2 3\n")).ReadModel(false)
+	assert.NoError(t, err)
+	assert.Nil(t, model.triangles[0].colors)
+}
+
+func TestOBJReader_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		obj  string
+		line int
+		msg  string
+	}{
+		{"v 1 2\n", 1, "expected 3 or 4 coordinates or 3 coordinates and a color, got 2 values"},
+		{"v 1 2 3 1 1\n", 1, "expected 3 or 4 coordinates or 3 coordinates and a color, got 5 values"},
+		{"v 1 2 x\n", 1, `invalid coordinate "x"`},
+		{"v 1 2 3\nv 1 2 4\nf 1 2\n", 3, "expected at least 3 vertices, got 2"},
+		{"v 1 2 3\n\n# comment\nf 1 1 2\n", 4, "vertex 2 out of range"},
+		{"v 1 2 3\nf 1 1 0\n", 2, "vertex 0 out of range"},
+		{"v 1 2 3\nf 1 1 -2\n", 2, "vertex -2 out of range"},
+		{"v 1 2 3\nf 1 a 1\n", 2, `invalid vertex reference "a"`},
+	} {
+		_, err := NewOBJReader(strings.NewReader(tc.obj)).ReadModel(false)
+		if assert.IsType(t, &ParseError{}, err, tc.obj) {
+			e := err.(*ParseError)
+			assert.Equal(t, "obj", e.Format)
+			assert.Equal(t, tc.line, e.Line, tc.obj)
+			assert.Equal(t, tc.msg, e.Msg, tc.obj)
+		}
+	}
+
+	_, err := NewOBJReader(strings.NewReader("")).ReadTriangle()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestOBJWriter(t *testing.T) {
+	model := Cube().Rot(rad(30), rad(12), 0)
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, NewOBJWriter(buf, false).WriteModel(model))
+	assert.Equal(t, 8, strings.Count(buf.String(), "\nv "))
+	assert.Equal(t, 12, strings.Count(buf.String(), "\nf "))
+
+	read, err := NewOBJReader(buf).ReadModel(false)
+	assert.NoError(t, err)
+	assert.Equal(t, len(model.triangles), len(read.triangles))
+	for i := range model.triangles {
+		assertAlmostEqualV4(t, model.triangles[i].v1, read.triangles[i].v1)
+		assertAlmostEqualV4(t, model.triangles[i].v2, read.triangles[i].v2)
+		assertAlmostEqualV4(t, model.triangles[i].v3, read.triangles[i].v3)
+	}
+}
+
+func TestOBJWriter_Normals(t *testing.T) {
+	buf := new(bytes.Buffer)
+	assert.NoError(t, NewOBJWriter(buf, true).WriteModel(&Model{[]Triangle{
+		*NewTriangle(0, 0, 0,  1, 0, 0,  1, 1, 0),
+		*NewTriangle(0, 0, 0,  1, 1, 0,  0, 1, 0),
+	}}))
+	assert.Equal(t, `# written by 3dgo
+# 4 vertices, 2 faces
+v 0 0 0
+v 1 0 0
+v 1 1 0
+v 0 1 0
+vn 0 0 1
+f 1//1 2//1 3//1
+f 1//1 3//1 4//1
+`, buf.String())
+}
@@ -0,0 +1,375 @@
+// A reader and writer for Geomview OFF files, including the COFF variant
+// with vertex colors.
+// http://www.geomview.org/docs/html/OFF.html
+//
+// Limitations: only reads 3-dimensional vertices. Normals and texture
+// coordinates are skipped and colormap indices are ignored.
+//
+//
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// OFFReader reads the faces of OFF files as triangles, splitting polygons
+// and keeping the colors of their vertices or faces.
+type OFFReader struct {
+	scanner   *bufio.Scanner
+	line      int	// current line number
+	text      string	// current line
+	header    bool	// whether the header has been read
+	colors    bool	// whether vertices have colors (COFF)
+	normals   bool	// whether vertices have normals (NOFF)
+	textures  bool	// whether vertices have texture coordinates (STOFF)
+	vertices  []V4
+	vcolors   []color.NRGBA	// of the vertices, nil when they have none
+	faces     []offFace	// faces not yet triangulated
+	fscale    float64	// scale of the face colors
+	triangles []Triangle	// triangles of the last face not yet returned
+}
+
+// offFace is a face as read from the file, with the unscaled components of
+// its color, if it has one.
+type offFace struct {
+	indices []int
+	color   []float64
+}
+
+// NewOFFReader returns a reader for OFF data.
+func NewOFFReader(reader io.Reader) *OFFReader {
+	return &OFFReader{scanner: bufio.NewScanner(reader)}
+}
+
+func (r *OFFReader) errorf(format string, args ...interface{}) error {
+	return &ParseError{
+		Format: "off",
+		Line:   r.line,
+		Text:   strings.TrimSpace(r.text),
+		Msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+// nextLine returns the whitespace separated fields of the next line that is
+// neither blank nor a comment, or nil when the end of the stream is reached.
+func (r *OFFReader) nextLine() ([]string, error) {
+	for r.scanner.Scan() {
+		r.line++
+		r.text = r.scanner.Text()
+		text := r.text
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+		if fields := strings.Fields(text); len(fields) > 0 {
+			return fields, nil
+		}
+	}
+	return nil, r.scanner.Err()
+}
+
+// expectLine is like nextLine, but fails at the end of the stream.
+func (r *OFFReader) expectLine(what string) ([]string, error) {
+	fields, err := r.nextLine()
+	if err == nil && fields == nil {
+		err = r.errorf("unexpected end of file, expected %s", what)
+	}
+	return fields, err
+}
+
+// parseFloats parses all fields as numbers.
+func (r *OFFReader) parseFloats(fields []string) ([]float64, error) {
+	values := make([]float64, len(fields))
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return nil, r.errorf("invalid number %q", f)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// parseColor parses the red, green, blue and optional alpha components of
+// a color. They are scaled by colorScale once all colors are read.
+func (r *OFFReader) parseColor(fields []string) ([]float64, error) {
+	if len(fields) < 3 || len(fields) > 4 {
+		return nil, r.errorf("expected 3 or 4 color components, got %d", len(fields))
+	}
+	return r.parseFloats(fields)
+}
+
+// colorScale returns the factor that scales the components of the colors
+// to bytes. Colors are floats from 0 to 1, unless any component exceeds 1,
+// in which case they are all integers from 0 to 255.
+func colorScale(colors [][]float64) float64 {
+	for _, c := range colors {
+		for _, v := range c {
+			if v > 1 {
+				return 1
+			}
+		}
+	}
+	return 255
+}
+
+// offColor scales the components of a color, which is opaque if it has no
+// alpha component.
+func offColor(values []float64, scale float64) color.NRGBA {
+	components := [4]uint8{3: 255}
+	for i, v := range values {
+		components[i] = uint8(math.Max(0, math.Min(255, math.Round(v * scale))))
+	}
+	return color.NRGBA{components[0], components[1], components[2], components[3]}
+}
+
+// readHeader parses the keyword, the counts, the vertices and the faces.
+// Faces are read ahead, so that the scale of their colors is known.
+func (r *OFFReader) readHeader() error {
+	fields, err := r.expectLine(`"OFF"`)
+	if err != nil {
+		return err
+	}
+	keyword := fields[0]
+	if !strings.HasSuffix(keyword, "OFF") {
+		return r.errorf("expected %q", "OFF")
+	}
+	prefix := strings.TrimSuffix(keyword, "OFF")
+	if strings.HasPrefix(prefix, "ST") {
+		r.textures, prefix = true, prefix[2:]
+	}
+	if strings.HasPrefix(prefix, "C") {
+		r.colors, prefix = true, prefix[1:]
+	}
+	if strings.HasPrefix(prefix, "N") {
+		r.normals, prefix = true, prefix[1:]
+	}
+	if prefix != "" {
+		return r.errorf("unsupported variant %q", keyword)
+	}
+
+	// the counts may follow the keyword on the same line:
+	counts := fields[1:]
+	if len(counts) == 0 {
+		if counts, err = r.expectLine("vertex and face counts"); err != nil {
+			return err
+		}
+	}
+	if len(counts) != 3 {
+		return r.errorf("expected vertex, face and edge counts")
+	}
+	var n [3]int
+	for i, c := range counts {
+		if n[i], err = strconv.Atoi(c); err != nil || n[i] < 0 {
+			return r.errorf("invalid count %q", c)
+		}
+	}
+
+	columns := 3
+	if r.normals {
+		columns += 3
+	}
+	var vcolors [][]float64
+	for i := 0; i < n[0]; i++ {
+		fields, err := r.expectLine(fmt.Sprintf("%d more vertices", n[0] - i))
+		if err != nil {
+			return err
+		}
+		values := fields
+		if r.textures {
+			if len(values) < 2 {
+				return r.errorf("expected texture coordinates")
+			}
+			values = values[:len(values) - 2]
+		}
+		if len(values) < columns || (!r.colors && len(values) > columns) {
+			return r.errorf("expected %d coordinates, got %d", columns, len(values))
+		}
+		coords, err := r.parseFloats(values[:3])
+		if err != nil {
+			return err
+		}
+		r.vertices = append(r.vertices, *NewV4(coords[0], coords[1], coords[2]))
+		if r.colors {
+			c, err := r.parseColor(values[columns:])
+			if err != nil {
+				return err
+			}
+			vcolors = append(vcolors, c)
+		}
+	}
+	if r.colors {
+		scale := colorScale(vcolors)
+		for _, c := range vcolors {
+			r.vcolors = append(r.vcolors, offColor(c, scale))
+		}
+	}
+
+	var fcolors [][]float64
+	for i := 0; i < n[1]; i++ {
+		face, err := r.readFace(n[1] - i)
+		if err != nil {
+			return err
+		}
+		r.faces = append(r.faces, face)
+		if face.color != nil {
+			fcolors = append(fcolors, face.color)
+		}
+	}
+	r.fscale = colorScale(fcolors)
+	return nil
+}
+
+// readFace reads the next face, with an optional color.
+func (r *OFFReader) readFace(left int) (offFace, error) {
+	fields, err := r.expectLine(fmt.Sprintf("%d more faces", left))
+	if err != nil {
+		return offFace{}, err
+	}
+	count, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return offFace{}, r.errorf("invalid vertex count %q", fields[0])
+	}
+	if count < 3 {
+		return offFace{}, r.errorf("expected at least 3 vertices, got %d", count)
+	}
+	if len(fields) < 1 + count {
+		return offFace{}, r.errorf("expected %d vertex indices, got %d", count, len(fields) - 1)
+	}
+
+	face := offFace{indices: make([]int, count)}
+	for i, f := range fields[1:1 + count] {
+		index, err := strconv.Atoi(f)
+		if err != nil {
+			return offFace{}, r.errorf("invalid vertex index %q", f)
+		}
+		if index < 0 || index >= len(r.vertices) {
+			return offFace{}, r.errorf("vertex %d out of range", index)
+		}
+		face.indices[i] = index
+	}
+
+	// a single value is an index into a colormap, which is ignored:
+	if rest := fields[1 + count:]; len(rest) > 1 {
+		if face.color, err = r.parseColor(rest); err != nil {
+			return offFace{}, err
+		}
+	}
+	return face, nil
+}
+
+// triangulate splits the face into triangles with the colors of the face
+// or of its vertices.
+func (r *OFFReader) triangulate(face offFace) []Triangle {
+	polygon := make([]V4, len(face.indices))
+	for i, index := range face.indices {
+		polygon[i] = r.vertices[index]
+	}
+	var triangles []Triangle
+	for _, t := range triangulate(polygon) {
+		triangle := Triangle{v1: polygon[t[0]], v2: polygon[t[1]], v3: polygon[t[2]]}
+		if face.color != nil {
+			c := offColor(face.color, r.fscale)
+			triangle.colors = &[3]color.NRGBA{c, c, c}
+		} else if r.vcolors != nil {
+			triangle.colors = &[3]color.NRGBA{
+				r.vcolors[face.indices[t[0]]], r.vcolors[face.indices[t[1]]], r.vcolors[face.indices[t[2]]]}
+		}
+		triangles = append(triangles, triangle)
+	}
+	return triangles
+}
+
+// ReadTriangle returns the next triangle from the stream. Polygons are
+// triangulated with ear clipping. Triangles carry the color of their face,
+// or else the colors of their vertices if the file has any. Colors are
+// floats from 0 to 1, unless any of the vertex or face colors has a
+// component above 1, in which case those are integers from 0 to 255. When
+// the end of the file is reached, io.EOF is returned. Malformed files
+// produce a *ParseError.
+func (r *OFFReader) ReadTriangle() (*Triangle, error) {
+	if !r.header {
+		if err := r.readHeader(); err != nil {
+			return nil, err
+		}
+		r.header = true
+	}
+	for len(r.triangles) == 0 {
+		if len(r.faces) == 0 {
+			return nil, io.EOF
+		}
+		r.triangles = r.triangulate(r.faces[0])
+		r.faces = r.faces[1:]
+	}
+	t := r.triangles[0]
+	r.triangles = r.triangles[1:]
+	return &t, nil
+}
+
+// ReadModel returns the model as defined in the loaded OFF file, which is
+// optionally scaled to fit in the unit cube like STLReader.ReadModel.
+func (r *OFFReader) ReadModel(scale bool) (*Model, error) {
+	return readModel(r, scale)
+}
+
+// OFFWriter serializes models as OFF files, or as COFF files when the
+// model has colors.
+type OFFWriter struct {
+	writer io.Writer
+}
+
+// NewOFFWriter returns a writer for OFF files.
+func NewOFFWriter(writer io.Writer) *OFFWriter {
+	return &OFFWriter{writer: writer}
+}
+
+// WriteModel writes the model's vertices and triangles. When any of the
+// triangles has colors, a COFF file with vertex colors is written. See
+// indexColored for how vertices are shared.
+func (w *OFFWriter) WriteModel(model *Model) error {
+	vertices, faces, colored := indexColored(model)
+	edges := make(map[Edge]bool)
+	for _, f := range faces {
+		for i := range f {
+			edges[newEdge(f[i], f[(i + 1) % 3])] = true
+		}
+	}
+
+	out := bufio.NewWriter(w.writer)
+	if colored {
+		fmt.Fprintln(out, "COFF")
+	} else {
+		fmt.Fprintln(out, "OFF")
+	}
+	fmt.Fprintln(out, "# written by 3dgo")
+	fmt.Fprintf(out, "%d %d %d\n", len(vertices), len(faces), len(edges))
+	for _, v := range vertices {
+		fmt.Fprintf(out, "%g %g %g", v.position.x, v.position.y, v.position.z)
+		if colored {
+			fmt.Fprintf(out, " %d %d %d %d", v.color.R, v.color.G, v.color.B, v.color.A)
+		}
+		fmt.Fprintln(out)
+	}
+	for _, f := range faces {
+		fmt.Fprintf(out, "3 %d %d %d\n", f[0], f[1], f[2])
+	}
+	return out.Flush()
+}
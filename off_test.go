@@ -0,0 +1,138 @@
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"strings"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func ExampleOFFReader() {
+	var off = `OFF
+# a square and a red triangle
+4 2 0
+0 0 0
+1 0 0
+1 1 0
+0 1 0
+4 0 1 2 3
+3 0 1 2  1.0 0.0 0.0 0.5
+`
+
+	model, err := NewOFFReader(strings.NewReader(off)).ReadModel(false)
+	fmt.Println(err)
+	for _, t := range model.triangles {
+		fmt.Println(t.v1, t.v2, t.v3, t.colors)
+	}
+
+	// Output:
+	// <nil>
+	// {0 1 0 1} {0 0 0 1} {1 0 0 1} <nil>
+	// {1 0 0 1} {1 1 0 1} {0 1 0 1} <nil>
+	// {0 0 0 1} {1 0 0 1} {1 1 0 1} &[{255 0 0 128} {255 0 0 128} {255 0 0 128}]
+}
+
+func TestOFFReader_COFF(t *testing.T) {
+	var off = `COFF 3 1 3
+0 0 0 255 0 0 255
+1 0 0 0 255 0 255
+1 1 0 0 0 255
+3 0 1 2
+`
+	model, err := NewOFFReader(strings.NewReader(off)).ReadModel(false)
+	assert.NoError(t, err)
+	assert.Len(t, model.triangles, 1)
+	assert.Equal(t, &[3]color.NRGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}}, model.triangles[0].colors)
+
+	// normals and texture coordinates are skipped:
+	off = "STCNOFF\n3 1 0\n0 0 0 0 0 1 1 1 1 1 .1 .2\n1 0 0 0 0 1 1 1 1 1 .1 .2\n1 1 0 0 0 1 1 1 1 1 .1 .2\n3 0 1 2\n"
+	model, err = NewOFFReader(strings.NewReader(off)).ReadModel(false)
+	assert.NoError(t, err)
+	assertAlmostEqualV4(t, *NewV4(1, 1, 0), model.triangles[0].v3)
+	assert.Equal(t, color.NRGBA{255, 255, 255, 255}, model.triangles[0].colors[0])
+}
+
+func TestOFFWriter(t *testing.T) {
+	buf := new(bytes.Buffer)
+	assert.NoError(t, NewOFFWriter(buf).WriteModel(Cube()))
+	assert.True(t, strings.HasPrefix(buf.String(), "OFF\n"))
+
+	read, err := NewOFFReader(buf).ReadModel(false)
+	assert.NoError(t, err)
+	assert.Equal(t, len(Cube().triangles), len(read.triangles))
+	for i, tr := range Cube().triangles {
+		assertAlmostEqualV4(t, tr.v1, read.triangles[i].v1)
+		assertAlmostEqualV4(t, tr.v2, read.triangles[i].v2)
+		assertAlmostEqualV4(t, tr.v3, read.triangles[i].v3)
+	}
+
+	colored := &Model{[]Triangle{*NewTriangle(0, 0, 0,  1, 0, 0,  1, 1, 0), *NewTriangle(0, 0, 0,  1, 1, 0,  0, 1, 0)}}
+	colored.triangles[0].colors = &[3]color.NRGBA{{255, 0, 0, 255}, {255, 0, 0, 255}, {0, 0, 255, 255}}
+
+	// vertices are not shared between triangles of different colors:
+	buf.Reset()
+	assert.NoError(t, NewOFFWriter(buf).WriteModel(colored))
+	assert.Equal(t, `COFF
+# written by 3dgo
+6 2 6
+0 0 0 255 0 0 255
+1 0 0 255 0 0 255
+1 1 0 0 0 255 255
+0 0 0 255 255 255 255
+1 1 0 255 255 255 255
+0 1 0 255 255 255 255
+3 0 1 2
+3 3 4 5
+`, buf.String())
+
+	read, err = NewOFFReader(buf).ReadModel(false)
+	assert.NoError(t, err)
+	assert.Equal(t, colored.triangles[0].colors, read.triangles[0].colors)
+}
+
+func TestOFFReader_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		off  string
+		line int
+		msg  string
+	}{
+		{"", 0, `unexpected end of file, expected "OFF"`},
+		{"PLY\n", 1, `expected "OFF"`},
+		{"4OFF\n", 1, `unsupported variant "4OFF"`},
+		{"OFF\n1 2\n", 2, "expected vertex, face and edge counts"},
+		{"OFF\n1 x 0\n", 2, `invalid count "x"`},
+		{"OFF 2 0 0\n0 0 0\n", 2, "unexpected end of file, expected 1 more vertices"},
+		{"OFF 1 0 0\n0 0\n", 2, "expected 3 coordinates, got 2"},
+		{"OFF 1 0 0\n0 0 0 1\n", 2, "expected 3 coordinates, got 4"},
+		{"OFF 1 0 0\n0 0 y\n", 2, `invalid number "y"`},
+		{"COFF 1 0 0\n0 0 0 1 1\n", 2, "expected 3 or 4 color components, got 2"},
+		{"OFF 3 1 0\n0 0 0\n1 0 0\n1 1 0\n", 4, "unexpected end of file, expected 1 more faces"},
+		{"OFF 3 1 0\n0 0 0\n1 0 0\n1 1 0\n2 0 1\n", 5, "expected at least 3 vertices, got 2"},
+		{"OFF 3 1 0\n0 0 0\n1 0 0\n1 1 0\n4 0 1 2\n", 5, "expected 4 vertex indices, got 3"},
+		{"OFF 3 1 0\n0 0 0\n1 0 0\n1 1 0\n3 0 1 3\n", 5, "vertex 3 out of range"},
+		{"OFF 3 1 0\n0 0 0\n1 0 0\n1 1 0\n3 0 1 2 1 1\n", 5, "expected 3 or 4 color components, got 2"},
+	} {
+		_, err := NewOFFReader(strings.NewReader(tc.off)).ReadModel(false)
+		if assert.IsType(t, &ParseError{}, err, tc.off) {
+			e := err.(*ParseError)
+			assert.Equal(t, "off", e.Format)
+			assert.Equal(t, tc.line, e.Line, tc.off)
+			assert.Equal(t, tc.msg, e.Msg, tc.off)
+		}
+	}
+}
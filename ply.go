@@ -0,0 +1,527 @@
+// A PLY (Polygon File Format) reader and writer for ASCII and binary files.
+// https://en.wikipedia.org/wiki/PLY_(file_format)
+//
+// Limitations: only reads the positions and colors of vertices and the
+// faces. Other properties and elements, like normals and edges, are skipped.
+//
+//
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// PLYFormat is the encoding of a PLY file.
+type PLYFormat int
+
+const (
+	PLYASCII PLYFormat = iota
+	PLYBinaryLittleEndian
+	PLYBinaryBigEndian
+)
+
+var plyFormats = map[string]PLYFormat{
+	"ascii":                PLYASCII,
+	"binary_little_endian": PLYBinaryLittleEndian,
+	"binary_big_endian":    PLYBinaryBigEndian,
+}
+
+func (f PLYFormat) String() string {
+	for name, format := range plyFormats {
+		if format == f {
+			return name
+		}
+	}
+	return fmt.Sprintf("PLYFormat(%d)", int(f))
+}
+
+func (f PLYFormat) byteOrder() binary.ByteOrder {
+	if f == PLYBinaryBigEndian {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+// plyTypeSizes holds the size in bytes of the scalar property types, under
+// both their original and their sized names.
+var plyTypeSizes = map[string]int{
+	"char": 1, "uchar": 1, "short": 2, "ushort": 2, "int": 4, "uint": 4, "float": 4, "double": 8,
+	"int8": 1, "uint8": 1, "int16": 2, "uint16": 2, "int32": 4, "uint32": 4, "float32": 4, "float64": 8,
+}
+
+// plyProperty is a property declaration of an element.
+type plyProperty struct {
+	name      string
+	typ       string	// the scalar type, or the type of the items of lists
+	countType string	// the type of the item count of lists, empty otherwise
+}
+
+// plyMaxListLength is the largest number of items of a list property,
+// which guards against corrupt counts.
+const plyMaxListLength = 1 << 24
+
+// plyElement is an element declaration, like "vertex" or "face".
+type plyElement struct {
+	name       string
+	count      int
+	properties []plyProperty
+	values     []float64	// of the scalar properties of the current record
+	lists      [][]float64	// of the list properties of the current record
+}
+
+// PLYReader reads the faces of PLY files as triangles, splitting polygons
+// and keeping the colors of their vertices.
+type PLYReader struct {
+	reader    *bufio.Reader
+	line      int	// current line number of the header and ASCII files
+	text      string	// current line
+	fields    []string	// the values left on the current line of ASCII files
+	header    bool	// whether the header has been read
+	format    PLYFormat
+	elements  []plyElement
+	element   int	// index of the element being read
+	records   int	// number of records of the element read or being read
+	position  [3]int	// indices of the vertex element's x, y and z properties
+	color     [4]int	// of its red, green, blue and alpha properties, or -1
+	indices   int	// index of the face element's vertex indices property
+	vertices  []V4
+	colors    []color.NRGBA	// of the vertices, nil when they have none
+	triangles []Triangle	// triangles of the last face not yet returned
+}
+
+// NewPLYReader returns a reader for ASCII or binary PLY data.
+func NewPLYReader(reader io.Reader) *PLYReader {
+	return &PLYReader{reader: bufio.NewReader(reader)}
+}
+
+// errorf returns a *ParseError for the current line, except for the data
+// of binary files, which has no lines and is identified by the element's
+// name and the 1-based record number instead.
+func (r *PLYReader) errorf(format string, args ...interface{}) error {
+	if r.header && r.format != PLYASCII {
+		return fmt.Errorf("ply: %s %d: %s", r.elements[r.element].name, r.records,
+			fmt.Sprintf(format, args...))
+	}
+	return &ParseError{
+		Format: "ply",
+		Line:   r.line,
+		Text:   strings.TrimSpace(r.text),
+		Msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+// nextLine returns the whitespace separated fields of the next non-blank
+// line, or nil when the end of the stream is reached.
+func (r *PLYReader) nextLine() ([]string, error) {
+	for {
+		text, err := r.reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if text == "" && err == io.EOF {
+			return nil, nil
+		}
+		r.line++
+		r.text = text
+		if fields := strings.Fields(text); len(fields) > 0 {
+			return fields, nil
+		}
+	}
+}
+
+// readHeader parses the header up to and including "end_header".
+func (r *PLYReader) readHeader() error {
+	fields, err := r.nextLine()
+	if err != nil {
+		return err
+	}
+	if len(fields) != 1 || fields[0] != "ply" {
+		return r.errorf("expected %q", "ply")
+	}
+
+	formatSeen := false
+	for {
+		fields, err := r.nextLine()
+		if err != nil {
+			return err
+		}
+		if fields == nil {
+			return r.errorf("unexpected end of file, expected %q", "end_header")
+		}
+		switch fields[0] {
+		case "format":
+			if len(fields) != 3 {
+				return r.errorf("expected format and version")
+			}
+			format, ok := plyFormats[fields[1]]
+			if !ok {
+				return r.errorf("unknown format %q", fields[1])
+			}
+			if fields[2] != "1.0" {
+				return r.errorf("unsupported version %q", fields[2])
+			}
+			r.format, formatSeen = format, true
+		case "comment", "obj_info":
+		case "element":
+			if len(fields) != 3 {
+				return r.errorf("expected element name and count")
+			}
+			count, err := strconv.Atoi(fields[2])
+			if err != nil || count < 0 {
+				return r.errorf("invalid element count %q", fields[2])
+			}
+			r.elements = append(r.elements, plyElement{name: fields[1], count: count})
+		case "property":
+			if len(r.elements) == 0 {
+				return r.errorf("property outside of element")
+			}
+			var p plyProperty
+			switch {
+			case len(fields) == 3:
+				p = plyProperty{name: fields[2], typ: fields[1]}
+			case len(fields) == 5 && fields[1] == "list":
+				p = plyProperty{name: fields[4], typ: fields[3], countType: fields[2]}
+			default:
+				return r.errorf("invalid property")
+			}
+			for _, typ := range []string{p.typ, p.countType} {
+				if _, ok := plyTypeSizes[typ]; !ok && typ != "" {
+					return r.errorf("unknown type %q", typ)
+				}
+			}
+			e := &r.elements[len(r.elements) - 1]
+			e.properties = append(e.properties, p)
+		case "end_header":
+			if !formatSeen {
+				return r.errorf("missing format")
+			}
+			return r.checkElements()
+		default:
+			return r.errorf("unexpected keyword %q", fields[0])
+		}
+	}
+}
+
+// readValue reads the next value of the specified scalar type.
+func (r *PLYReader) readValue(typ string) (float64, error) {
+	if r.format == PLYASCII {
+		if len(r.fields) == 0 {
+			return 0, r.errorf("too few values")
+		}
+		f := r.fields[0]
+		r.fields = r.fields[1:]
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return 0, r.errorf("invalid value %q", f)
+		}
+		return v, nil
+	}
+
+	var buf [8]byte
+	b := buf[:plyTypeSizes[typ]]
+	if _, err := io.ReadFull(r.reader, b); err != nil {
+		return 0, fmt.Errorf("ply: truncated %s %d: %w",
+			r.elements[r.element].name, r.records, io.ErrUnexpectedEOF)
+	}
+	order := r.format.byteOrder()
+	switch typ {
+	case "char", "int8":
+		return float64(int8(b[0])), nil
+	case "uchar", "uint8":
+		return float64(b[0]), nil
+	case "short", "int16":
+		return float64(int16(order.Uint16(b))), nil
+	case "ushort", "uint16":
+		return float64(order.Uint16(b)), nil
+	case "int", "int32":
+		return float64(int32(order.Uint32(b))), nil
+	case "uint", "uint32":
+		return float64(order.Uint32(b)), nil
+	case "float", "float32":
+		return float64(math.Float32frombits(order.Uint32(b))), nil
+	default:
+		return math.Float64frombits(order.Uint64(b)), nil
+	}
+}
+
+// readRecord reads the values of the next record of the current element
+// into the element's values and lists, by property index.
+func (r *PLYReader) readRecord() error {
+	e := &r.elements[r.element]
+	if r.format == PLYASCII {
+		fields, err := r.nextLine()
+		if err != nil {
+			return err
+		}
+		if fields == nil {
+			return r.errorf("unexpected end of file, expected %d more %s records",
+				e.count - r.records, e.name)
+		}
+		r.fields = fields
+	}
+	r.records++
+
+	for i, p := range e.properties {
+		if p.countType == "" {
+			v, err := r.readValue(p.typ)
+			if err != nil {
+				return err
+			}
+			e.values[i] = v
+			continue
+		}
+		n, err := r.readValue(p.countType)
+		if err != nil {
+			return err
+		}
+		if n < 0 || n != math.Trunc(n) || n > plyMaxListLength {
+			return r.errorf("invalid list length %g", n)
+		}
+		// the list grows as its items are read, so that corrupt lengths of
+		// truncated files don't allocate:
+		list := e.lists[i][:0]
+		for j := 0; j < int(n); j++ {
+			v, err := r.readValue(p.typ)
+			if err != nil {
+				return err
+			}
+			list = append(list, v)
+		}
+		e.lists[i] = list
+	}
+	if r.format == PLYASCII && len(r.fields) > 0 {
+		return r.errorf("too many values")
+	}
+	return nil
+}
+
+// index returns the index of the element's named property, or -1 if it has
+// none.
+func (e *plyElement) index(name string) int {
+	for i, p := range e.properties {
+		if p.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkElements verifies that the vertex and face elements declare the
+// properties that make up the model and resolves their indices, once for
+// all records.
+func (r *PLYReader) checkElements() error {
+	r.color = [4]int{-1, -1, -1, -1}
+	for i := range r.elements {
+		e := &r.elements[i]
+		e.values = make([]float64, len(e.properties))
+		e.lists = make([][]float64, len(e.properties))
+		switch e.name {
+		case "vertex":
+			for j, name := range []string{"x", "y", "z"} {
+				k := e.index(name)
+				if k < 0 || e.properties[k].countType != "" {
+					return r.errorf("vertex element lacks property %q", name)
+				}
+				r.position[j] = k
+			}
+			if red, green, blue := e.index("red"), e.index("green"), e.index("blue"); red >= 0 && green >= 0 && blue >= 0 {
+				r.color = [4]int{red, green, blue, e.index("alpha")}
+			}
+		case "face":
+			k := e.index("vertex_indices")
+			if k < 0 {
+				k = e.index("vertex_index")
+			}
+			if k < 0 || e.properties[k].countType == "" {
+				return r.errorf("face element lacks list property %q", "vertex_indices")
+			}
+			r.indices = k
+		}
+	}
+	return nil
+}
+
+// colorComponent converts a color property to 8 bits. Floating point
+// colors range from 0 to 1, integer colors from 0 to 255.
+func colorComponent(v float64, typ string) uint8 {
+	if typ == "float" || typ == "float32" || typ == "double" || typ == "float64" {
+		v *= 255
+	}
+	return uint8(math.Max(0, math.Min(255, math.Round(v))))
+}
+
+// readVertex reads the next vertex record.
+func (r *PLYReader) readVertex() error {
+	if err := r.readRecord(); err != nil {
+		return err
+	}
+	e := &r.elements[r.element]
+	r.vertices = append(r.vertices,
+		*NewV4(e.values[r.position[0]], e.values[r.position[1]], e.values[r.position[2]]))
+
+	if r.color[0] >= 0 {
+		c := [4]uint8{3: 255}
+		for i, k := range r.color {
+			if k >= 0 {
+				c[i] = colorComponent(e.values[k], e.properties[k].typ)
+			}
+		}
+		r.colors = append(r.colors, color.NRGBA{c[0], c[1], c[2], c[3]})
+	}
+	return nil
+}
+
+// readFace reads the next face record and triangulates it.
+func (r *PLYReader) readFace() error {
+	if err := r.readRecord(); err != nil {
+		return err
+	}
+	indices := r.elements[r.element].lists[r.indices]
+	if len(indices) < 3 {
+		return r.errorf("expected at least 3 vertices, got %d", len(indices))
+	}
+
+	polygon := make([]V4, len(indices))
+	for i, index := range indices {
+		if index < 0 || int(index) >= len(r.vertices) || index != math.Trunc(index) {
+			return r.errorf("vertex %g out of range", index)
+		}
+		polygon[i] = r.vertices[int(index)]
+	}
+	for _, t := range triangulate(polygon) {
+		triangle := Triangle{v1: polygon[t[0]], v2: polygon[t[1]], v3: polygon[t[2]]}
+		if r.colors != nil {
+			triangle.colors = &[3]color.NRGBA{
+				r.colors[int(indices[t[0]])], r.colors[int(indices[t[1]])], r.colors[int(indices[t[2]])]}
+		}
+		r.triangles = append(r.triangles, triangle)
+	}
+	return nil
+}
+
+// ReadTriangle returns the next triangle from the stream. Polygons are
+// triangulated with ear clipping and triangles carry the colors of their
+// vertices if the file has any. When the end of the file is reached, io.EOF
+// is returned. Malformed headers and ASCII files produce a *ParseError.
+func (r *PLYReader) ReadTriangle() (*Triangle, error) {
+	if !r.header {
+		if err := r.readHeader(); err != nil {
+			return nil, err
+		}
+		r.header = true
+	}
+
+	for len(r.triangles) == 0 {
+		for r.element < len(r.elements) && r.records == r.elements[r.element].count {
+			r.element, r.records = r.element + 1, 0
+		}
+		if r.element == len(r.elements) {
+			return nil, io.EOF
+		}
+
+		var err error
+		switch r.elements[r.element].name {
+		case "vertex":
+			err = r.readVertex()
+		case "face":
+			err = r.readFace()
+		default:
+			err = r.readRecord()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	t := r.triangles[0]
+	r.triangles = r.triangles[1:]
+	return &t, nil
+}
+
+// ReadModel returns the model as defined in the loaded PLY file, which is
+// optionally scaled to fit in the unit cube like STLReader.ReadModel.
+func (r *PLYReader) ReadModel(scale bool) (*Model, error) {
+	return readModel(r, scale)
+}
+
+// PLYWriter serializes models as PLY files, in which triangles share
+// identical vertices.
+type PLYWriter struct {
+	writer io.Writer
+	format PLYFormat
+}
+
+// NewPLYWriter returns a writer that produces PLY in the specified encoding.
+func NewPLYWriter(writer io.Writer, format PLYFormat) *PLYWriter {
+	return &PLYWriter{writer: writer, format: format}
+}
+
+// WriteModel writes the model's vertices and triangles. When any of the
+// triangles has colors, the vertices get red, green, blue and alpha
+// properties. See indexColored for how vertices are shared.
+func (w *PLYWriter) WriteModel(model *Model) error {
+	vertices, faces, colored := indexColored(model)
+
+	out := bufio.NewWriter(w.writer)
+	fmt.Fprintln(out, "ply")
+	fmt.Fprintf(out, "format %s 1.0\n", w.format)
+	fmt.Fprintln(out, "comment written by 3dgo")
+	fmt.Fprintf(out, "element vertex %d\n", len(vertices))
+	for _, p := range []string{"x", "y", "z"} {
+		fmt.Fprintf(out, "property float %s\n", p)
+	}
+	if colored {
+		for _, p := range []string{"red", "green", "blue", "alpha"} {
+			fmt.Fprintf(out, "property uchar %s\n", p)
+		}
+	}
+	fmt.Fprintf(out, "element face %d\n", len(faces))
+	fmt.Fprintln(out, "property list uchar int vertex_indices")
+	fmt.Fprintln(out, "end_header")
+
+	if w.format == PLYASCII {
+		for _, v := range vertices {
+			fmt.Fprintf(out, "%g %g %g", float32(v.position.x), float32(v.position.y), float32(v.position.z))
+			if colored {
+				fmt.Fprintf(out, " %d %d %d %d", v.color.R, v.color.G, v.color.B, v.color.A)
+			}
+			fmt.Fprintln(out)
+		}
+		for _, f := range faces {
+			fmt.Fprintf(out, "3 %d %d %d\n", f[0], f[1], f[2])
+		}
+		return out.Flush()
+	}
+
+	order := w.format.byteOrder()
+	for _, v := range vertices {
+		binary.Write(out, order, []float32{float32(v.position.x), float32(v.position.y), float32(v.position.z)})
+		if colored {
+			out.Write([]byte{v.color.R, v.color.G, v.color.B, v.color.A})
+		}
+	}
+	for _, f := range faces {
+		out.WriteByte(3)
+		binary.Write(out, order, []int32{int32(f[0]), int32(f[1]), int32(f[2])})
+	}
+	return out.Flush()
+}
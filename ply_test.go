@@ -0,0 +1,180 @@
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+const coloredSquarePLY = `ply
+format ascii 1.0
+comment a red and blue square
+element vertex 4
+property float x
+property float y
+property float z
+property uchar red
+property uchar green
+property uchar blue
+element face 1
+property list uchar int vertex_indices
+element edge 1
+property int vertex1
+property int vertex2
+end_header
+0 0 0 255 0 0
+1 0 0 255 0 0
+1 1 0 0 0 255
+0 1 0 0 0 255
+4 0 1 2 3
+0 1
+`
+
+func ExamplePLYReader() {
+	reader := NewPLYReader(strings.NewReader(coloredSquarePLY))
+	for {
+		t, err := reader.ReadTriangle()
+		if err != nil {
+			fmt.Println(err)
+			break
+		}
+		fmt.Println(t.v1, t.v2, t.v3, *t.colors)
+	}
+
+	// Output:
+	// {0 1 0 1} {0 0 0 1} {1 0 0 1} [{0 0 255 255} {255 0 0 255} {255 0 0 255}]
+	// {1 0 0 1} {1 1 0 1} {0 1 0 1} [{255 0 0 255} {0 0 255 255} {0 0 255 255}]
+	// EOF
+}
+
+func TestPLYReader_FloatColors(t *testing.T) {
+	ply := `ply
+format ascii 1.0
+element vertex 3
+property double x
+property double y
+property double z
+property float red
+property float green
+property float blue
+property float alpha
+element face 1
+property list uchar uint vertex_index
+end_header
+0 0 0 1 .5 0 1
+1 0 0 1 .5 0 1
+1 1 0 1 .5 0 .5
+3 0 1 2
+`
+	model, err := NewPLYReader(strings.NewReader(ply)).ReadModel(false)
+	assert.NoError(t, err)
+	assert.Len(t, model.triangles, 1)
+	assert.Equal(t, color.NRGBA{255, 128, 0, 128}, model.triangles[0].colors[2])
+}
+
+func assertPLYRoundTrip(t *testing.T, model *Model, format PLYFormat) *Model {
+	buf := new(bytes.Buffer)
+	assert.NoError(t, NewPLYWriter(buf, format).WriteModel(model))
+	read, err := NewPLYReader(buf).ReadModel(false)
+	assert.NoError(t, err)
+
+	assert.Equal(t, len(model.triangles), len(read.triangles))
+	for i := range model.triangles {
+		assertAlmostEqualV4(t, model.triangles[i].v1, read.triangles[i].v1)
+		assertAlmostEqualV4(t, model.triangles[i].v2, read.triangles[i].v2)
+		assertAlmostEqualV4(t, model.triangles[i].v3, read.triangles[i].v3)
+		assert.Equal(t, model.triangles[i].colors, read.triangles[i].colors)
+	}
+	return read
+}
+
+func TestPLYWriter(t *testing.T) {
+	colored, err := NewPLYReader(strings.NewReader(coloredSquarePLY)).ReadModel(false)
+	assert.NoError(t, err)
+
+	for _, format := range []PLYFormat{PLYASCII, PLYBinaryLittleEndian, PLYBinaryBigEndian} {
+		assertPLYRoundTrip(t, Cube(), format)
+		assertPLYRoundTrip(t, colored, format)
+	}
+
+	// vertices are shared:
+	buf := new(bytes.Buffer)
+	assert.NoError(t, NewPLYWriter(buf, PLYASCII).WriteModel(colored))
+	assert.Contains(t, buf.String(), "element vertex 4\n")
+	assert.Contains(t, buf.String(), "0 1 0 0 0 255 255\n")
+}
+
+func TestPLYReader_Errors(t *testing.T) {
+	header := "ply\nformat ascii 1.0\nelement vertex 3\nproperty float x\nproperty float y\nproperty float z\n" +
+		"element face 1\nproperty list uchar int vertex_indices\nend_header\n"
+	for _, tc := range []struct {
+		ply  string
+		line int
+		msg  string
+	}{
+		{"solid\n", 1, `expected "ply"`},
+		{"ply\nformat ascii 2.0\n", 2, `unsupported version "2.0"`},
+		{"ply\nformat utf8 1.0\n", 2, `unknown format "utf8"`},
+		{"ply\nend_header\n", 2, "missing format"},
+		{"ply\nformat ascii 1.0\nproperty float x\n", 3, "property outside of element"},
+		{"ply\nformat ascii 1.0\nelement vertex 1\nproperty real x\n", 4, `unknown type "real"`},
+		{"ply\nformat ascii 1.0\nelement vertex 1\n", 3, `unexpected end of file, expected "end_header"`},
+		{"ply\nformat ascii 1.0\nelement vertex -1\n", 3, `invalid element count "-1"`},
+		{"ply\nformat ascii 1.0\nelement vertex 1\nproperty float px\nproperty float y\nproperty float z\nend_header\n",
+			7, `vertex element lacks property "x"`},
+		{"ply\nformat ascii 1.0\nelement face 1\nproperty int vertex_indices\nend_header\n",
+			5, `face element lacks list property "vertex_indices"`},
+		{header + "0 0 0\n1 0 0\n", 11, "unexpected end of file, expected 1 more vertex records"},
+		{header + "0 0 0\n1 0 0\n1 1\n", 12, "too few values"},
+		{header + "0 0 0\n1 0 0\n1 1 0 0\n", 12, "too many values"},
+		{header + "0 0 0\n1 0 0\n1 1 x\n", 12, `invalid value "x"`},
+		{header + "0 0 0\n1 0 0\n1 1 0\n2 0 1\n", 13, "expected at least 3 vertices, got 2"},
+		{header + "0 0 0\n1 0 0\n1 1 0\n3 0 1 3\n", 13, "vertex 3 out of range"},
+	} {
+		_, err := NewPLYReader(strings.NewReader(tc.ply)).ReadModel(false)
+		if assert.IsType(t, &ParseError{}, err, tc.ply) {
+			e := err.(*ParseError)
+			assert.Equal(t, "ply", e.Format)
+			assert.Equal(t, tc.line, e.Line, tc.ply)
+			assert.Equal(t, tc.msg, e.Msg, tc.ply)
+		}
+	}
+}
+
+func TestPLYReader_BinaryErrors(t *testing.T) {
+	buf := new(bytes.Buffer)
+	assert.NoError(t, NewPLYWriter(buf, PLYBinaryLittleEndian).WriteModel(Cube()))
+	data := buf.Bytes()
+
+	_, err := NewPLYReader(bytes.NewReader(data[:len(data) - 5])).ReadModel(false)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.EqualError(t, err, "ply: truncated face 12: unexpected EOF")
+
+	// corrupts the last vertex index of the last face:
+	data[len(data) - 4] = 100
+	_, err = NewPLYReader(bytes.NewReader(data)).ReadModel(false)
+	assert.EqualError(t, err, "ply: face 12: vertex 100 out of range")
+
+	// corrupt list lengths fail rather than allocate:
+	ply := "ply\nformat binary_big_endian 1.0\nelement vertex 0\nproperty float x\nproperty float y\nproperty float z\n" +
+		"element face 1\nproperty list uint int vertex_indices\nend_header\n\xff\xff\xff\xff"
+	_, err = NewPLYReader(strings.NewReader(ply)).ReadModel(false)
+	assert.EqualError(t, err, "ply: face 1: invalid list length 4.294967295e+09")
+}
@@ -15,33 +15,346 @@ package main
 
 import "math"
 
+const (
+	defaultNear = .1	// distance to the near clipping plane
+	defaultFar  = 1000.	// distance to the far clipping plane
+)
+
+// Projector maps camera space vertices onto a raster. A projection matrix
+// takes them into homogeneous clip space, where triangles are clipped
+// against the view volume, after which perspective division yields
+// normalized device coordinates (NDC) that the viewport matrix maps onto
+// the raster.
 type Projector struct {
-	aov float64
-	size int
-	plane float64
-	scale float64
-	clipping float64	// frustum near clipping plane
+	width, height int	// raster size in pixels
+	lens func(aspect float64) *M4	// creates the projection for an aspect ratio
+	projection M4	// camera space to clip space
+	viewport M4	// NDC to raster space
+	frustum Frustum	// the view volume in camera space
+	eye V4	// homogeneous camera space eye point, a direction for parallel projections
+}
+
+// FOVAxis selects the raster axis along which a projector's angle of view,
+// or orthographic view volume, is measured. The extent along the other axis
+// follows from the raster's aspect ratio.
+type FOVAxis int
+
+const (
+	HorizontalFOV FOVAxis = iota
+	VerticalFOV
+)
+
+// clipPlane is a plane through which vertices are clipped or culled.
+// Homogeneous points p for which Dot(n, p) + d * p.w >= 0 are on the
+// inside. Planes created by newClipPlane have a unit normal n, making the
+// distance to points with w=1 Euclidean.
+type clipPlane struct {
+	n V4
+	d float64
+}
+
+func newClipPlane(n V4, d float64) clipPlane {
+	l := n.Length()
+	n.Normalize()
+	return clipPlane{n: n, d: d / l}
+}
+
+func (c *clipPlane) distance(v V4) float64 {
+	return Dot(c.n, v) + c.d * v.w
+}
+
+// clipVolume holds the planes of the clip space view volume
+// -w <= x, y, z <= w, starting with the near plane so that the vertices
+// that remain have positive w.
+var clipVolume = []clipPlane{
+	{n: V4{z: 1}, d: 1},	// near: -w <= z
+	{n: V4{z: -1}, d: 1},	// far: z <= w
+	{n: V4{x: 1}, d: 1},	// left: -w <= x
+	{n: V4{x: -1}, d: 1},	// right: x <= w
+	{n: V4{y: 1}, d: 1},	// bottom: -w <= y
+	{n: V4{y: -1}, d: 1},	// top: y <= w
+}
+
+// Frustum is the camera space volume that is visible through a Projector,
+// bounded by its near, far and side planes.
+type Frustum struct {
+	planes []clipPlane
+}
+
+// FrustumM returns the camera space view volume of the specified
+// projection matrix, extracting its planes from the matrix rows.
+func FrustumM(m *M4) Frustum {
+	// http://www8.cs.umu.se/kurser/5DV051/HT12/lab/plane_extraction.pdf
+	var plane = func(sign float64, a float64, b float64, c float64, d float64) clipPlane {
+		return newClipPlane(V4{x: m.d0 + sign * a, y: m.d1 + sign * b, z: m.d2 + sign * c}, m.d3 + sign * d)
+	}
+	return Frustum{[]clipPlane{
+		plane(1, m.c0, m.c1, m.c2, m.c3),	// near
+		plane(-1, m.c0, m.c1, m.c2, m.c3),	// far
+		plane(1, m.a0, m.a1, m.a2, m.a3),	// left
+		plane(-1, m.a0, m.a1, m.a2, m.a3),	// right
+		plane(1, m.b0, m.b1, m.b2, m.b3),	// bottom
+		plane(-1, m.b0, m.b1, m.b2, m.b3),	// top
+	}}
+}
+
+// IntersectsSphere returns false when the sphere lies entirely outside the
+// frustum. Spheres that straddle the corners of the frustum may produce
+// false positives.
+func (f *Frustum) IntersectsSphere(s Sphere) bool {
+	for i := range f.planes {
+		if f.planes[i].distance(s.center) < -s.radius {
+			return false
+		}
+	}
+	return true
 }
 
+// IntersectsAABB returns false when the box lies entirely outside the
+// frustum. Boxes that straddle the corners of the frustum may produce false
+// positives.
+func (f *Frustum) IntersectsAABB(b AABB) bool {
+	for i := range f.planes {
+		plane := &f.planes[i]
+
+		// the corner furthest along the plane's normal:
+		corner := b.min
+		if plane.n.x > 0 {
+			corner.x = b.max.x
+		}
+		if plane.n.y > 0 {
+			corner.y = b.max.y
+		}
+		if plane.n.z > 0 {
+			corner.z = b.max.z
+		}
+		if plane.distance(corner) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// NewProjector returns a perspective projector for a square raster with
+// the specified angle of view in degrees.
 func NewProjector(resolution int, angleOfView float64) *Projector {
-	aov := rad(angleOfView)
-	plane := math.Tan(aov / 2.0)	// half the width of projection plane
-	p := Projector{
-		aov: aov,
-		size: resolution,
-		plane: plane,
-		scale: float64(resolution) / (plane * 2.0),
-		clipping: -.1,
+	return NewPerspectiveProjector(resolution, resolution, angleOfView, HorizontalFOV)
+}
+
+// NewPerspectiveProjector returns a perspective projector for a raster of
+// the specified size, with the specified angle of view in degrees along
+// the specified axis.
+func NewPerspectiveProjector(width int, height int, angleOfView float64, axis FOVAxis) *Projector {
+	half := math.Tan(rad(angleOfView) / 2)
+	return newProjector(width, height, func(aspect float64) *M4 {
+		if axis == HorizontalFOV {
+			return PerspectiveM(2 * math.Atan(half / aspect), aspect, defaultNear, defaultFar)
+		}
+		return PerspectiveM(2 * math.Atan(half), aspect, defaultNear, defaultFar)
+	})
+}
+
+// NewOrthoProjector returns a projector for orthographic (parallel)
+// projection onto a square raster, which does not foreshorten distant
+// geometry. The view volume is `width` camera space units wide and high.
+func NewOrthoProjector(resolution int, width float64) *Projector {
+	return NewOrthographicProjector(resolution, resolution, width, HorizontalFOV)
+}
+
+// NewOrthographicProjector returns an orthographic projector for a raster of
+// the specified size, whose view volume is `extent` camera space units
+// across along the specified axis.
+func NewOrthographicProjector(width int, height int, extent float64, axis FOVAxis) *Projector {
+	return newProjector(width, height, func(aspect float64) *M4 {
+		w, h := extent, extent / aspect
+		if axis == VerticalFOV {
+			w, h = extent * aspect, extent
+		}
+		return OrthoM(-w / 2, w / 2, -h / 2, h / 2, defaultNear, defaultFar)
+	})
+}
+
+// NewProjectorM returns a projector for a raster of the specified size that
+// uses the specified projection matrix, such as one created by PerspectiveM
+// or OrthoM. The projection is not adjusted when the projector is resized.
+func NewProjectorM(width int, height int, projection *M4) *Projector {
+	m := *projection
+	return newProjector(width, height, func(float64) *M4 {
+		return &m
+	})
+}
+
+func newProjector(width int, height int, lens func(aspect float64) *M4) *Projector {
+	p := &Projector{lens: lens}
+	p.Resize(width, height)
+	return p
+}
+
+// Resize adapts the projector to a raster of the specified size, keeping
+// the angle of view along its axis and updating the other to the new aspect
+// ratio so that models are not stretched.
+func (p *Projector) Resize(width int, height int) {
+	p.width, p.height = width, height
+	p.projection = *p.lens(float64(width) / float64(height))
+	p.viewport = *ViewportM(float64(width), float64(height))
+	p.frustum = FrustumM(&p.projection)
+	if p.projection.d3 == 0 {
+		p.eye = V4{w: 1}	// perspective, where view rays meet at the origin
+	} else {
+		p.eye = V4{z: 1}	// parallel, where view rays run along the z-axis
 	}
-	return &p
+}
+
+// Size returns the width and height of the projector's raster.
+func (p *Projector) Size() (int, int) {
+	return p.width, p.height
+}
+
+// UnitsPerPixel returns the size of a pixel in camera space units at the
+// specified distance in front of the camera.
+func (p *Projector) UnitsPerPixel(distance float64) float64 {
+	if p.projection.d3 == 0 {
+		// perspective projection, where clip space w holds the distance
+		return 2 * distance / (p.projection.a0 * float64(p.width))
+	}
+	return 2 / (p.projection.a0 * float64(p.width))
+}
+
+// Projection returns the projector's projection matrix.
+func (p *Projector) Projection() *M4 {
+	return &p.projection
+}
+
+// Viewport returns the projector's viewport matrix.
+func (p *Projector) Viewport() *M4 {
+	return &p.viewport
+}
+
+// Frustum returns the projector's view frustum in camera space.
+func (p *Projector) Frustum() *Frustum {
+	return &p.frustum
+}
+
+func (p *Projector) toScreen(v V4) V4 {
+	// Maps a clip space vertex to raster coordinates through perspective
+	// division and the viewport transformation. The z component holds the
+	// depth, which is larger for nearer vertices and varies linearly across
+	// projected triangles.
+	return *v.PerspectiveDivide().MultiplyM(&p.viewport)
 }
 
 func (p *Projector) project(v V4) V2 {
-	// Given a vertex, computes its (x, y) pixel projection on the
-	// projection plane, normalizes to NDC space, converts to raster space and
-	// returns the screen pixel coordinates.
-	return V2{
-		x: ((v.x / -v.z) + p.plane) * p.scale,
-		y: ((v.y / -v.z) + p.plane) * p.scale,
-	}
-}
\ No newline at end of file
+	// Given a camera space vertex, computes its (x, y) pixel projection on
+	// the raster. The vertex is not clipped.
+	s := p.toScreen(*v.MultiplyM(&p.projection))
+	return V2{x: s.x, y: s.y}
+}
+
+func (p *Projector) facing(t *Triangle) bool {
+	// Returns whether the camera space triangle faces the camera, by
+	// testing on which side of the triangle's plane the eye lies.
+	n := t.Normal()
+	return Dot(n, p.eye) - Dot(n, t.v1) * p.eye.w > 0
+}
+
+// clipLine transforms the camera space line segment into clip space and
+// clips it against the view volume. It returns the clip space end points
+// of the part that remains, and false when nothing does.
+func (p *Projector) clipLine(a V4, b V4) (V4, V4, bool) {
+	a.MultiplyM(&p.projection)
+	b.MultiplyM(&p.projection)
+
+	for i := range clipVolume {
+		plane := &clipVolume[i]
+		da, db := plane.distance(a), plane.distance(b)
+		if da < 0 && db < 0 {
+			return a, b, false
+		} else if da < 0 || db < 0 {
+			f := da / (da - db)
+			v := V4{
+				x: a.x + (b.x - a.x) * f,
+				y: a.y + (b.y - a.y) * f,
+				z: a.z + (b.z - a.z) * f,
+				w: a.w + (b.w - a.w) * f,
+			}
+			if da < 0 {
+				a = v
+			} else {
+				b = v
+			}
+		}
+	}
+	return a, b, true
+}
+
+// clip transforms the camera space triangle into clip space and clips it
+// against the view volume using the Sutherland-Hodgman algorithm. It
+// returns the clip space vertices of the convex polygon that remains, which
+// is nil when the triangle lies entirely outside the view volume.
+func (p *Projector) clip(t *Triangle) []V4 {
+	polygon := []V4{t.v1, t.v2, t.v3}
+	for i := range polygon {
+		polygon[i].MultiplyM(&p.projection)
+	}
+
+	for i := range clipVolume {
+		plane := &clipVolume[i]
+		if len(polygon) == 0 {
+			break
+		}
+
+		inside := true
+		for _, v := range polygon {
+			inside = inside && plane.distance(v) >= 0
+		}
+		if inside {
+			continue
+		}
+
+		clipped := make([]V4, 0, len(polygon) + 1)
+		for j, a := range polygon {
+			b := polygon[(j + 1) % len(polygon)]
+			da, db := plane.distance(a), plane.distance(b)
+			if da >= 0 {
+				clipped = append(clipped, a)
+			}
+			if (da >= 0) != (db >= 0) {
+				f := da / (da - db)
+				clipped = append(clipped, V4{
+					x: a.x + (b.x - a.x) * f,
+					y: a.y + (b.y - a.y) * f,
+					z: a.z + (b.z - a.z) * f,
+					w: a.w + (b.w - a.w) * f,
+				})
+			}
+		}
+		polygon = clipped
+	}
+	if len(polygon) < 3 {
+		return nil
+	}
+	return polygon
+}
+
+// screen runs the camera space triangle through the pipeline and returns
+// the clipped polygon in raster coordinates, with the depth in z. It returns
+// nil when nothing remains after clipping, or when the polygon faces away
+// from the camera (back-face culling), which is determined by its winding
+// direction: counter-clockwise polygons face the camera.
+func (p *Projector) screen(t *Triangle) []V4 {
+	polygon := p.clip(t)
+	area := 0.
+	for i := range polygon {
+		polygon[i] = p.toScreen(polygon[i])
+		if i > 0 {
+			area += polygon[i-1].x * polygon[i].y - polygon[i].x * polygon[i-1].y
+		}
+	}
+	if n := len(polygon); n > 0 {
+		area += polygon[n-1].x * polygon[0].y - polygon[0].x * polygon[n-1].y
+	}
+	if area <= 0 {
+		return nil
+	}
+	return polygon
+}
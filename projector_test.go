@@ -0,0 +1,170 @@
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+// assertInClipVolume asserts that all vertices are inside the clip space
+// view volume.
+func assertInClipVolume(t *testing.T, polygon []V4) {
+	for _, v := range polygon {
+		for i := range clipVolume {
+			assert.True(t, clipVolume[i].distance(v) >= -1e-9, "%v outside of plane %d", v, i)
+		}
+	}
+}
+
+func TestProjector_Clip(t *testing.T) {
+	p := NewProjector(100, 90)
+
+	// entirely inside the frustum:
+	polygon := p.clip(NewTriangle(0, 0, -1,  .5, 0, -1,  0, .5, -1))
+	assert.Len(t, polygon, 3)
+	assertAlmostEqualV4(t, *NewV4(0, 0, -1).MultiplyM(&p.projection), polygon[0])
+
+	// entirely behind the near plane:
+	assert.Nil(t, p.clip(NewTriangle(0, 0, 1,  .5, 0, 1,  0, .5, -.05)))
+
+	// one vertex behind the near plane cuts the triangle into a quad:
+	polygon = p.clip(NewTriangle(0, 0, -1,  0, .05, 1,  -.05, 0, -1))
+	assert.Len(t, polygon, 4)
+	assertInClipVolume(t, polygon)
+
+	// the new vertex lies on the near plane, at w = .1:
+	assert.InDelta(t, .1, polygon[1].w, 1e-9)
+	assert.InDelta(t, -.1, polygon[1].z, 1e-9)
+	assert.InDelta(t, .05 * .45, polygon[1].y, 1e-9)
+
+	// two vertices behind the near plane leave a smaller triangle:
+	assert.Len(t, p.clip(NewTriangle(0, 0, -1,  .05, 0, 1,  0, .05, 1)), 3)
+
+	// clipping against the right side of the frustum (x / -z <= 1):
+	polygon = p.clip(NewTriangle(0, 0, -1,  3, 0, -1,  0, .5, -1))
+	assert.Len(t, polygon, 4)
+	assertInClipVolume(t, polygon)
+
+	// and against the far plane:
+	assert.Nil(t, p.clip(NewTriangle(0, 0, -1001,  .5, 0, -1001,  0, .5, -1001)))
+	polygon = p.clip(NewTriangle(0, 0, -900,  .5, 0, -1100,  0, .5, -900))
+	assert.Len(t, polygon, 4)
+	assertInClipVolume(t, polygon)
+}
+
+func TestProjector_Screen(t *testing.T) {
+	p := NewProjector(100, 90)
+
+	// counter-clockwise winding faces the camera:
+	polygon := p.screen(NewTriangle(1, 1, -2,  -1, 1, -2,  -1, -1, -2))
+	assert.Len(t, polygon, 3)
+	assertAlmostEqualV4(t, V4{75, 75, polygon[0].z, 1}, polygon[0])
+	assertAlmostEqualV4(t, V4{25, 75, polygon[1].z, 1}, polygon[1])
+	assertAlmostEqualV4(t, V4{25, 25, polygon[2].z, 1}, polygon[2])
+	assert.True(t, polygon[0].z > 0 && polygon[0].z < 1)
+
+	assert.Nil(t, p.screen(NewTriangle(1, 1, -2,  -1, -1, -2,  -1, 1, -2)))
+
+	// nearer vertices have a larger depth:
+	near, far := p.project(*NewV4(0, 0, -2)), p.project(*NewV4(0, 0, -3))
+	assert.Equal(t, near, far)
+	assert.True(t,
+		p.screen(NewTriangle(1, 1, -2,  -1, 1, -2,  -1, -1, -2))[0].z >
+		p.screen(NewTriangle(1, 1, -3,  -1, 1, -3,  -1, -1, -3))[0].z)
+}
+
+func TestOrthoProjector(t *testing.T) {
+	p := NewOrthoProjector(100, 4)
+
+	// distance does not affect the projection:
+	assert.Equal(t, V2{75, 50}, p.project(*NewV4(1, 0, -1)))
+	assert.Equal(t, V2{75, 50}, p.project(*NewV4(1, 0, -100)))
+	assert.Equal(t, V2{0, 100}, p.project(*NewV4(-2, 2, -3)))
+
+	// all view rays are parallel to the z-axis:
+	assert.NotNil(t, p.screen(NewTriangle(1, 0, -1,  2, 0, -1,  1, 1, -1)))
+	assert.Nil(t, p.screen(NewTriangle(1, 0, -1,  1, 1, -1,  2, 0, -1)))
+	assert.Nil(t, p.screen(NewTriangle(1, 0, -1,  1, 1, -1,  1, 0, -2)))
+
+	polygon := p.clip(NewTriangle(0, 0, -1,  3, 0, -1,  0, 1, -1))
+	assert.Len(t, polygon, 4)
+	assertInClipVolume(t, polygon)
+	assert.Nil(t, p.clip(NewTriangle(3, 0, -1,  4, 0, -1,  3, 1, -1)))
+
+	assert.True(t, p.Frustum().IntersectsSphere(Sphere{*NewV4(2.5, 0, -100), 1}))
+	assert.False(t, p.Frustum().IntersectsSphere(Sphere{*NewV4(3.5, 0, -100), 1}))
+	assert.False(t, p.Frustum().IntersectsSphere(Sphere{*NewV4(0, 0, -1002), 1}))
+}
+
+func TestFrustumM(t *testing.T) {
+	f := FrustumM(PerspectiveM(rad(90), 2, 1, 10))
+	assert.Len(t, f.planes, 6)
+
+	for _, v := range []*V4{NewV4(0, 0, -1), NewV4(0, 0, -9.99), NewV4(1.99, .99, -1), NewV4(-19.9, -9.9, -9.99)} {
+		for i := range f.planes {
+			assert.True(t, f.planes[i].distance(*v) >= 0, "%v outside of plane %d", v, i)
+		}
+	}
+	for _, v := range []*V4{NewV4(0, 0, -.9), NewV4(0, 0, -10.1), NewV4(2.01, 0, -1), NewV4(0, 1.01, -1)} {
+		assert.False(t, f.IntersectsSphere(Sphere{*v, 0}), "%v inside", v)
+	}
+
+	// planes have unit normals, making distances Euclidean:
+	assert.InDelta(t, 1., f.planes[1].distance(*NewV4(0, 0, -9)), 1e-6)
+}
+
+func TestPerspectiveProjector_AspectRatio(t *testing.T) {
+	// the horizontal angle of view spans the width of the raster:
+	p := NewPerspectiveProjector(200, 100, 90, HorizontalFOV)
+	assert.InDelta(t, 200., p.project(*NewV4(1, 0, -1)).x, 1e-6)
+	assert.InDelta(t, 50., p.project(*NewV4(1, 0, -1)).y, 1e-6)
+	assert.InDelta(t, 100., p.project(*NewV4(0, .5, -1)).y, 1e-6)
+
+	// the vertical angle of view spans the height of the raster:
+	p = NewPerspectiveProjector(200, 100, 90, VerticalFOV)
+	assert.InDelta(t, 100., p.project(*NewV4(0, 1, -1)).y, 1e-6)
+	assert.InDelta(t, 200., p.project(*NewV4(2, 0, -1)).x, 1e-6)
+
+	// resizing keeps the angle of view along its axis:
+	p.Resize(100, 100)
+	w, h := p.Size()
+	assert.Equal(t, 100, w)
+	assert.Equal(t, 100, h)
+	assert.InDelta(t, 100., p.project(*NewV4(0, 1, -1)).y, 1e-6)
+	assert.InDelta(t, 100., p.project(*NewV4(1, 0, -1)).x, 1e-6)
+	assert.False(t, p.Frustum().IntersectsSphere(Sphere{*NewV4(1.5, 0, -1), .1}))
+}
+
+func TestOrthographicProjector_AspectRatio(t *testing.T) {
+	p := NewOrthographicProjector(200, 100, 4, HorizontalFOV)
+	assert.Equal(t, V2{200, 100}, p.project(*NewV4(2, 1, -5)))
+
+	p = NewOrthographicProjector(200, 100, 4, VerticalFOV)
+	assert.Equal(t, V2{200, 100}, p.project(*NewV4(4, 2, -5)))
+
+	// a custom projection is stretched to the raster:
+	p = NewProjectorM(200, 100, OrthoM(-1, 1, -1, 1, 1, 10))
+	assert.Equal(t, V2{200, 100}, p.project(*NewV4(1, 1, -5)))
+}
+
+func TestProjector_UnitsPerPixel(t *testing.T) {
+	p := NewProjector(100, 90)
+	assert.InDelta(t, .02, p.UnitsPerPixel(1), 1e-9)
+	assert.InDelta(t, .1, p.UnitsPerPixel(5), 1e-9)
+
+	p = NewOrthoProjector(100, 4)
+	assert.InDelta(t, .04, p.UnitsPerPixel(1), 1e-9)
+	assert.InDelta(t, .04, p.UnitsPerPixel(5), 1e-9)
+}
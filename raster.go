@@ -0,0 +1,215 @@
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"sort"
+)
+
+// Raster is a headless DepthCanvas that draws into an in-memory image, so
+// models can be rendered without a display.
+type Raster struct {
+	img     *image.RGBA
+	depth   []float64	// depth buffer, larger values being nearer
+	color   color.RGBA	// stroke and fill color
+	figures []figure	// the current path
+}
+
+// figure is a single connected series of lines in a path.
+type figure struct {
+	points []V2
+	closed bool
+}
+
+// NewRaster returns a raster of the specified size with a white background
+// that draws in black.
+func NewRaster(width int, height int) *Raster {
+	r := &Raster{
+		img:   image.NewRGBA(image.Rect(0, 0, width, height)),
+		depth: make([]float64, width * height),
+		color: color.RGBA{A: 255},
+	}
+	r.Clear()
+	return r
+}
+
+// Image returns the raster's underlying image.
+func (r *Raster) Image() *image.RGBA {
+	return r.img
+}
+
+func (r *Raster) Clear() {
+	draw.Draw(r.img, r.img.Bounds(), image.White, image.Point{}, draw.Src)
+	for i := range r.depth {
+		r.depth[i] = math.Inf(-1)
+	}
+}
+
+func (r *Raster) SetColor(c color.Color) {
+	r.color = color.RGBAModel.Convert(c).(color.RGBA)
+}
+
+func (r *Raster) MoveTo(x float64, y float64) {
+	r.figures = append(r.figures, figure{points: []V2{{x, y}}})
+}
+
+func (r *Raster) LineTo(x float64, y float64) {
+	if len(r.figures) == 0 {
+		r.MoveTo(x, y)
+		return
+	}
+	f := &r.figures[len(r.figures) - 1]
+	f.points = append(f.points, V2{x, y})
+}
+
+func (r *Raster) ClosePath() {
+	if len(r.figures) > 0 {
+		r.figures[len(r.figures) - 1].closed = true
+	}
+}
+
+func (r *Raster) Stroke() {
+	for _, f := range r.figures {
+		for i := 1; i < len(f.points); i++ {
+			r.DrawLine(f.points[i-1].x, f.points[i-1].y, f.points[i].x, f.points[i].y)
+		}
+		if f.closed && len(f.points) > 2 {
+			first, last := f.points[0], f.points[len(f.points) - 1]
+			r.DrawLine(last.x, last.y, first.x, first.y)
+		}
+	}
+	r.figures = r.figures[:0]
+}
+
+// Fill scan-converts the figures in the current path using the even-odd
+// rule, sampling at pixel centers. All figures are implicitly closed.
+func (r *Raster) Fill() {
+	bounds := r.img.Bounds()
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for _, f := range r.figures {
+		for _, p := range f.points {
+			minY, maxY = math.Min(minY, p.y), math.Max(maxY, p.y)
+		}
+	}
+	y0 := int(math.Max(math.Ceil(minY - .5), float64(bounds.Min.Y)))
+	y1 := int(math.Min(math.Floor(maxY - .5), float64(bounds.Max.Y - 1)))
+
+	var xs []float64
+	for y := y0; y <= y1; y++ {
+		cy := float64(y) + .5
+		xs = xs[:0]
+		for _, f := range r.figures {
+			for i, a := range f.points {
+				b := f.points[(i + 1) % len(f.points)]
+				if (a.y <= cy) != (b.y <= cy) {
+					xs = append(xs, a.x + (cy - a.y) / (b.y - a.y) * (b.x - a.x))
+				}
+			}
+		}
+		sort.Float64s(xs)
+		for i := 0; i + 1 < len(xs); i += 2 {
+			x0 := int(math.Max(math.Ceil(xs[i] - .5), float64(bounds.Min.X)))
+			x1 := int(math.Min(math.Floor(xs[i+1] - .5), float64(bounds.Max.X - 1)))
+			for x := x0; x <= x1; x++ {
+				r.img.SetRGBA(x, y, r.color)
+			}
+		}
+	}
+	r.figures = r.figures[:0]
+}
+
+// FillTriangle scan-converts the triangle, sampling at pixel centers, and
+// only sets the pixels where the interpolated depth is nearer than the
+// depth buffer.
+func (r *Raster) FillTriangle(v1 V4, v2 V4, v3 V4) {
+	area := edge(v1, v2, v3.x, v3.y)
+	if area == 0 {
+		return
+	}
+
+	bounds := r.img.Bounds()
+	x0 := int(math.Max(math.Floor(math.Min(v1.x, math.Min(v2.x, v3.x))), float64(bounds.Min.X)))
+	x1 := int(math.Min(math.Ceil(math.Max(v1.x, math.Max(v2.x, v3.x))), float64(bounds.Max.X - 1)))
+	y0 := int(math.Max(math.Floor(math.Min(v1.y, math.Min(v2.y, v3.y))), float64(bounds.Min.Y)))
+	y1 := int(math.Min(math.Ceil(math.Max(v1.y, math.Max(v2.y, v3.y))), float64(bounds.Max.Y - 1)))
+
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			px, py := float64(x) + .5, float64(y) + .5
+
+			// barycentric weights, normalized for either winding direction:
+			w1 := edge(v2, v3, px, py) / area
+			w2 := edge(v3, v1, px, py) / area
+			w3 := edge(v1, v2, px, py) / area
+			if w1 < 0 || w2 < 0 || w3 < 0 {
+				continue
+			}
+
+			i := (y - bounds.Min.Y) * bounds.Dx() + (x - bounds.Min.X)
+			if z := w1 * v1.z + w2 * v2.z + w3 * v3.z; z > r.depth[i] {
+				r.depth[i] = z
+				r.img.SetRGBA(x, y, r.color)
+			}
+		}
+	}
+}
+
+// edge returns twice the signed area of the triangle (a, b, (x, y)).
+func edge(a V4, b V4, x float64, y float64) float64 {
+	return (b.x - a.x) * (y - a.y) - (b.y - a.y) * (x - a.x)
+}
+
+// DrawLine draws a single pixel wide line between the specified points
+// using Bresenham's algorithm. Pixels outside the raster are discarded.
+func (r *Raster) DrawLine(x0 float64, y0 float64, x1 float64, y1 float64) {
+	ax, ay := int(math.Round(x0)), int(math.Round(y0))
+	bx, by := int(math.Round(x1)), int(math.Round(y1))
+
+	dx, sx := bx - ax, 1
+	if dx < 0 {
+		dx, sx = -dx, -1
+	}
+	dy, sy := by - ay, 1
+	if dy < 0 {
+		dy, sy = -dy, -1
+	}
+
+	err := dx - dy
+	for {
+		r.img.SetRGBA(ax, ay, r.color)
+		if ax == bx && ay == by {
+			return
+		}
+		e2 := 2 * err
+		if e2 > -dy {
+			err -= dy
+			ax += sx
+		}
+		if e2 < dx {
+			err += dx
+			ay += sy
+		}
+	}
+}
+
+// WritePNG encodes the raster as a PNG image.
+func (r *Raster) WritePNG(w io.Writer) error {
+	return png.Encode(w, r.img)
+}
@@ -0,0 +1,91 @@
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+var black = color.RGBA{A: 255}
+var white = color.RGBA{255, 255, 255, 255}
+
+func TestRaster_DrawLine(t *testing.T) {
+	r := NewRaster(10, 10)
+	r.DrawLine(1, 1, 8, 4)
+	for _, p := range [][2]int{{1, 1}, {3, 2}, {4, 2}, {6, 3}, {8, 4}} {
+		assert.Equal(t, black, r.Image().RGBAAt(p[0], p[1]), "pixel %v", p)
+	}
+	assert.Equal(t, white, r.Image().RGBAAt(1, 2))
+
+	// steep lines, drawn backwards and partly outside of the raster:
+	r = NewRaster(10, 10)
+	r.DrawLine(5, 12, 5, -3)
+	for y := 0; y < 10; y++ {
+		assert.Equal(t, black, r.Image().RGBAAt(5, y), "pixel (5, %d)", y)
+	}
+}
+
+func TestRaster_Wireframe(t *testing.T) {
+	r := NewRaster(100, 100)
+	NewWireframe(Cube()).Draw(r, NewProjector(100, 52), TransM(NewV4(0, 0, -2)), false, CullBackFaces)
+
+	// the front face of the cube spans the center of the raster and its
+	// diagonal is visible:
+	assert.Equal(t, white, r.Image().RGBAAt(40, 50))
+	assert.Equal(t, black, r.Image().RGBAAt(50, 50))
+
+	// nothing is drawn when the model is behind the camera:
+	r = NewRaster(100, 100)
+	NewWireframe(Cube()).Draw(r, NewProjector(100, 52), TransM(NewV4(0, 0, 2)), false, CullBackFaces)
+	for i := 0; i < len(r.Image().Pix); i++ {
+		if r.Image().Pix[i] != 255 {
+			t.Fatalf("unexpected pixel at %d", i / 4)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, r.WritePNG(buf))
+	img, err := png.Decode(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, r.Image().Bounds(), img.Bounds())
+}
+
+func TestRaster_Fill(t *testing.T) {
+	r := NewRaster(10, 10)
+	r.SetColor(color.RGBA{255, 0, 0, 255})
+	r.MoveTo(2, 2)
+	r.LineTo(8, 2)
+	r.LineTo(8, 6)
+	r.LineTo(2, 6)
+	r.Fill()
+
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			if x >= 2 && x < 8 && y >= 2 && y < 6 {
+				assert.Equal(t, color.RGBA{255, 0, 0, 255}, r.Image().RGBAAt(x, y), "pixel (%d, %d)", x, y)
+			} else {
+				assert.Equal(t, white, r.Image().RGBAAt(x, y), "pixel (%d, %d)", x, y)
+			}
+		}
+	}
+
+	// the path is discarded after filling:
+	r.Clear()
+	r.Stroke()
+	assert.Equal(t, white, r.Image().RGBAAt(2, 2))
+}
@@ -15,18 +15,140 @@ package main
 
 import (
 	"github.com/andlabs/ui"
+	"image"
+	"image/color"
 	"time"
 	"math"
 	"os"
 )
 
+// areaCanvas is a Canvas backed by an andlabs/ui drawing area.
+type areaCanvas struct {
+	dp    *ui.AreaDrawParams
+	brush ui.Brush
+	path  *ui.Path
+}
+
+func newAreaCanvas(dp *ui.AreaDrawParams) *areaCanvas {
+	return &areaCanvas{
+		dp:    dp,
+		brush: ui.Brush{A: 1, Type: ui.Solid},
+	}
+}
+
+func (c *areaCanvas) Clear() {
+	path := ui.NewPath(ui.Winding)
+	path.AddRectangle(0, 0, c.dp.AreaWidth, c.dp.AreaHeight)
+	path.End()
+	c.dp.Context.Fill(path, &ui.Brush{Type: ui.Solid, R: 1, G: 1, B: 1, A: 1})
+	path.Free()
+}
+
+func (c *areaCanvas) SetColor(col color.Color) {
+	rgba := color.NRGBAModel.Convert(col).(color.NRGBA)
+	c.brush.R = float64(rgba.R) / 255
+	c.brush.G = float64(rgba.G) / 255
+	c.brush.B = float64(rgba.B) / 255
+	c.brush.A = float64(rgba.A) / 255
+}
+
+func (c *areaCanvas) MoveTo(x float64, y float64) {
+	if c.path == nil {
+		c.path = ui.NewPath(ui.Alternate)
+	}
+	c.path.NewFigure(x, y)
+}
+
+func (c *areaCanvas) LineTo(x float64, y float64) {
+	if c.path == nil {
+		c.MoveTo(x, y)
+		return
+	}
+	c.path.LineTo(x, y)
+}
+
+func (c *areaCanvas) ClosePath() {
+	if c.path != nil {
+		c.path.CloseFigure()
+	}
+}
+
+func (c *areaCanvas) Stroke() {
+	if c.path != nil {
+		c.path.End()
+		c.dp.Context.Stroke(c.path, &c.brush,
+			&ui.StrokeParams{ui.FlatCap, ui.MiterJoin, 1, 2, nil, 1})
+		c.path.Free()
+		c.path = nil
+	}
+}
+
+func (c *areaCanvas) Fill() {
+	if c.path != nil {
+		c.path.End()
+		c.dp.Context.Fill(c.path, &c.brush)
+		c.path.Free()
+		c.path = nil
+	}
+}
+
+// DrawImage paints the image onto the area. As andlabs/ui cannot draw
+// images, every horizontal run of equally colored pixels is filled as a
+// rectangle, with one path per color.
+func (c *areaCanvas) DrawImage(img *image.RGBA) {
+	paths := make(map[color.RGBA]*ui.Path)
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; {
+			col := img.RGBAAt(x, y)
+			end := x + 1
+			for end < b.Max.X && img.RGBAAt(end, y) == col {
+				end++
+			}
+			path, found := paths[col]
+			if !found {
+				path = ui.NewPath(ui.Winding)
+				paths[col] = path
+			}
+			path.AddRectangle(float64(x), float64(y), float64(end - x), 1)
+			x = end
+		}
+	}
+	for col, path := range paths {
+		path.End()
+		c.SetColor(col)
+		c.dp.Context.Fill(path, &c.brush)
+		path.Free()
+	}
+}
+
+// batchSize is the number of triangles that are culled as a whole.
+const batchSize = 64
+
+// drag tracks a mouse drag that either rotates the model, or pans or
+// dollies the camera.
+type drag struct {
+	button uint
+	pan    bool
+	dolly  bool
+	x, y   float64	// the last mouse position
+}
+
 type Renderer struct {
 	a         *ui.Area
-	dp        *ui.AreaDrawParams
 	model     Model
-    cameraMatrix M4
+	batches   []Batch	// the model split up for frustum culling
+	wireframe *Wireframe	// the model's edge list
+	solid     bool	// flat shaded rather than wireframe
+	quads     bool	// hides the wireframe's edges between coplanar triangles
+	hidden    HiddenLines	// hidden-line removal of the wireframe
+	light     Light
+	rotation  M4	// model orientation set with the arcball
+	drag      *drag	// the mouse drag in progress
+    camera    Camera
 	projector Projector
 	rotTime   float64 // seconds per rotation
+	tilt      float64	// of the rotation axis, in radians
 }
 
 func (r *Renderer) mainLoop() {
@@ -36,79 +158,140 @@ func (r *Renderer) mainLoop() {
 	}
 }
 
-func (r *Renderer) drawModel(a *ui.Area, dp *ui.AreaDrawParams, model *Model) {
-
-	path := ui.NewPath(ui.Winding)
-	for _, t := range model.triangles {
-		// back-face culling:
-		if Dot(t.v1, t.Normal()) < 0. &&
-			// frustum near-plane clipping:
-			t.v1.z <= r.projector.clipping && t.v2.z <= r.projector.clipping && t.v3.z <= r.projector.clipping {
-
-			point := r.projector.project(t.v1)
-			path.NewFigure(point.x, point.y)
-
-			point = r.projector.project(t.v2)
-			path.LineTo(point.x, point.y)
-
-			point = r.projector.project(t.v3)
-			path.LineTo(point.x, point.y)
-			path.CloseFigure()
+func (r *Renderer) drawModel(c Canvas, m *M4) {
+	if r.solid {
+		if r.batches == nil {
+			r.batches = NewBatches(&r.model, batchSize)
+		}
+		model := Cull(r.batches, r.projector.Frustum(), m)
+		col := color.RGBA{0x70, 0x90, 0xc0, 0xff}
+		if ac, ok := c.(*areaCanvas); ok {
+			// the area has no depth buffer, which the painter's algorithm
+			// can't make up for, so the model is z-buffered in a raster:
+			width, height := r.projector.Size()
+			raster := NewRaster(width, height)
+			DrawSolid(raster, &r.projector, model, &r.light, col)
+			ac.DrawImage(raster.Image())
+		} else {
+			DrawSolid(c, &r.projector, model, &r.light, col)
+		}
+	} else {
+		if r.wireframe == nil {
+			r.wireframe = NewWireframe(&r.model)
 		}
+		r.wireframe.Draw(c, &r.projector, m, r.quads, r.hidden)
 	}
-	path.End()
-
-	dp.Context.Stroke(path,
-		&ui.Brush{A:1, Type:ui.Solid},
-		&ui.StrokeParams{ui.FlatCap, ui.MiterJoin, 1, 2, nil, 1})
-	path.Free()
 }
 
 func (r *Renderer) Draw(a *ui.Area, dp *ui.AreaDrawParams) {
+	if w, h := int(dp.AreaWidth), int(dp.AreaHeight); w > 0 && h > 0 {
+		if pw, ph := r.projector.Size(); w != pw || h != ph {
+			r.projector.Resize(w, h)
+		}
+	}
+
 	angle := (float64(time.Now().UnixNano() % (int64(r.rotTime * 1e9))) / 1e9) *
 				((2 * math.Pi) / r.rotTime)
-    mat := RotX(math.Pi/2.).Mul(RotY(rad(23.4))).Mul(RotZ(angle))
-    model := r.model.Clone().Apply(mat)
-
-    r.drawModel(a, dp, model.Apply(r.cameraMatrix.Inverse()))
+    r.drawModel(newAreaCanvas(dp), r.camera.View().Mul(&r.rotation).Mul(Turntable(r.tilt, angle)))
 }
 
-func (r Renderer) MouseEvent(a *ui.Area, me *ui.AreaMouseEvent) {
-	return
+// MouseEvent rotates the model with an arcball when dragging with the left
+// button, pans the camera when dragging with the middle button or with shift
+// held and dollies it when dragging up or down with the right button or
+// with ctrl held. As andlabs/ui does not report the scroll wheel, it does
+// not dolly; the + and - keys do.
+func (r *Renderer) MouseEvent(a *ui.Area, me *ui.AreaMouseEvent) {
+	switch {
+	case me.Down >= 1 && me.Down <= 3:
+		r.drag = &drag{
+			button: me.Down,
+			pan:    me.Down == 2 || (me.Down == 1 && me.Modifiers & ui.Shift != 0),
+			dolly:  me.Down == 3 || (me.Down == 1 && me.Modifiers & ui.Ctrl != 0),
+			x:      me.X,
+			y:      me.Y,
+		}
+	case r.drag != nil && me.Up == r.drag.button:
+		r.drag = nil
+	case r.drag != nil && r.drag.dolly:
+		// dragging up moves towards the target:
+		k := r.projector.UnitsPerPixel(r.camera.Distance())
+		r.camera.Dolly((r.drag.y - me.Y) * k)
+		r.drag.x, r.drag.y = me.X, me.Y
+	case r.drag != nil && r.drag.pan:
+		// keeps the point under the cursor at the target's distance:
+		k := r.projector.UnitsPerPixel(r.camera.Distance())
+		r.camera.Pan(-(me.X - r.drag.x) * k, -(me.Y - r.drag.y) * k)
+		r.drag.x, r.drag.y = me.X, me.Y
+	case r.drag != nil:
+		axis, angle := Arcball(r.drag.x, r.drag.y, me.X, me.Y, me.AreaWidth, me.AreaHeight)
+		if angle != 0 {
+			// rotate about the model's center, along the camera space axis:
+			world := axis.MultiplyM(r.camera.View().Inverse())
+			r.rotation = *Rot(NewV4(0, 0, 0), world, angle).Mul(&r.rotation)
+		}
+		r.drag.x, r.drag.y = me.X, me.Y
+	}
 }
 
-func (r Renderer) MouseCrossed(a *ui.Area, left bool) {
+func (r *Renderer) MouseCrossed(a *ui.Area, left bool) {
 	return
 }
 
-func (r Renderer) DragBroken(a *ui.Area) {
-	return
+func (r *Renderer) DragBroken(a *ui.Area) {
+	r.drag = nil
 }
 
 func (r *Renderer) KeyEvent(a *ui.Area, ke *ui.AreaKeyEvent) (handled bool) {
     step := .25
 
     if !ke.Up {
-        tm := new(M4).SetIdentity()
-
         switch ke.Key {
-        case int32('w'):
-            tm = TransM(NewV4(0, 0, -step))
-        case int32('s'):
-            tm = TransM(NewV4(0, 0, step))
+        case int32('w'), int32('+'), int32('='):
+            r.camera.Dolly(step)
+        case int32('s'), int32('-'):
+            r.camera.Dolly(-step)
         case int32('a'):
-            tm = TransM(NewV4(-step, 0, 0))
+            r.camera.Pan(-step, 0)
         case int32('d'):
-            tm = TransM(NewV4(step, 0, 0))
+            r.camera.Pan(step, 0)
+        case int32('f'):
+            r.solid = !r.solid
+        case int32('q'):
+            r.quads = !r.quads
+        case int32('h'):
+            r.hidden = (r.hidden + 1) % (DashHidden + 1)
         }
 
+        // arrow keys turn the camera, or orbit its target with shift:
+        angle := rad(step*4)
+        orbit := ke.Modifiers & ui.Shift != 0
         switch ke.ExtKey {
         case ui.Left:
-            tm = RotY(rad(step*4))
+            if orbit {
+                r.camera.Orbit(-angle, 0)
+            } else {
+                r.camera.Yaw(angle)
+            }
         case ui.Right:
-            tm = RotY(rad(-step*4))
+            if orbit {
+                r.camera.Orbit(angle, 0)
+            } else {
+                r.camera.Yaw(-angle)
+            }
+        // raster y points down, making camera space -y up on screen:
+        case ui.Up:
+            if orbit {
+                r.camera.Orbit(0, -angle)
+            } else {
+                r.camera.Pitch(-angle)
+            }
+        case ui.Down:
+            if orbit {
+                r.camera.Orbit(0, angle)
+            } else {
+                r.camera.Pitch(angle)
+            }
         }
-        r.cameraMatrix.Mul(tm)
         return true
     }
 	return
@@ -136,34 +319,34 @@ func Cube() (*Model) {
 }
 
 func main() {
-	var model Model
-	if len(os.Args) > 1 {
-		f, err := os.Open(os.Args[1])
-		if err != nil {
-			panic(err)
-		}
-		model = *NewSTLReader(f).ReadModel(true)
-		f.Close()
-	} else {
-		model = *Cube().Rot(math.Pi / 4, math.Pi / 4, math.Pi / 4)
-	}
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
 
-	err := ui.Main(func() {
+// viewModel opens a window that animates the model's turntable rotation
+// until it is closed.
+func viewModel(model *Model, s *settings) error {
+	return ui.Main(func() {
 
 		renderer := Renderer{
 			a:    nil,
-			dp:        nil,
-			projector: *NewProjector(600, 52),
-			model: model,
-			cameraMatrix: *TransM(NewV4(0, 0, 2)),
-			rotTime: 30,	// seconds per full rotation
+			projector: *s.projector(),
+			model: *model,
+			camera: *s.newCamera(),
+			rotation: *new(M4).SetIdentity(),
+			light: *NewLight(NewV4(1, 1, -1), .2),
+			rotTime: s.period,
+			tilt: rad(s.tilt),
+			solid: s.solid,
+			quads: s.hideCoplanar,
+			hidden: s.hiddenLines(),
 		}
 		canvas := ui.NewArea(&renderer)
 		renderer.a = canvas
 
 		box := ui.NewVerticalBox()
 		box.Append(canvas, true)
-		window := ui.NewWindow("Perspective Projection", renderer.projector.size, renderer.projector.size, false)
+		width, height := renderer.projector.Size()
+		window := ui.NewWindow("Perspective Projection", width, height, false)
 		window.SetMargined(false)
 		window.SetChild(box)
 		window.OnClosing(func(*ui.Window) bool {
@@ -176,7 +359,4 @@ func main() {
 			renderer.mainLoop()
 		}()
 	})
-	if err != nil {
-		panic(err)
-	}
 }
@@ -0,0 +1,121 @@
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"image/color"
+	"math"
+	"sort"
+)
+
+// Light is a directional light source for flat shading.
+type Light struct {
+	direction V4	// unit vector the light travels along, in camera space
+	ambient   float64	// minimum intensity of surfaces facing away
+}
+
+// NewLight returns a directional light shining along the specified vector,
+// with an ambient intensity between 0 and 1.
+func NewLight(direction *V4, ambient float64) *Light {
+	d := *direction
+	return &Light{direction: *d.Normalize(), ambient: ambient}
+}
+
+// Intensity returns the Lambertian light intensity on the triangle's
+// surface, between the light's ambient intensity and 1.
+func (l *Light) Intensity(t *Triangle) float64 {
+	n := t.Normal()
+	lambert := math.Max(0, -Dot(*n.Normalize(), l.direction))
+	return l.ambient + (1 - l.ambient) * lambert
+}
+
+func (l *Light) shade(t *Triangle, c color.Color) color.Color {
+	i := l.Intensity(t)
+	rgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return color.NRGBA{
+		R: uint8(float64(rgba.R) * i),
+		G: uint8(float64(rgba.G) * i),
+		B: uint8(float64(rgba.B) * i),
+		A: rgba.A,
+	}
+}
+
+// color returns the average of the triangle's vertex colors, or `col` for
+// triangles without colors.
+func (t *Triangle) color(col color.Color) color.Color {
+	if t.colors == nil {
+		return col
+	}
+	var r, g, b, a int
+	for _, c := range t.colors {
+		r, g, b, a = r + int(c.R), g + int(c.G), b + int(c.B), a + int(c.A)
+	}
+	return color.NRGBA{uint8(r / 3), uint8(g / 3), uint8(b / 3), uint8(a / 3)}
+}
+
+// DepthCanvas is a Canvas with a depth buffer, which performs hidden
+// surface removal per pixel.
+type DepthCanvas interface {
+	Canvas
+
+	// FillTriangle fills the triangle between the specified raster
+	// coordinates, where it is nearer than anything drawn before. The z
+	// components hold the vertices' depth values, which are larger for
+	// nearer vertices and are interpolated linearly.
+	FillTriangle(v1 V4, v2 V4, v3 V4)
+}
+
+// DrawSolid fills the visible triangles of the specified model, which must
+// already be transformed into camera space, flat shaded by the light. The
+// triangles are drawn in `col`, or the average of their vertex colors if
+// they have any.
+// Triangles are clipped to the view frustum. Canvases with a depth buffer
+// are z-buffered, others fall back to the painter's algorithm, drawing
+// triangles back to front.
+func DrawSolid(c Canvas, p *Projector, model *Model, light *Light, col color.Color) {
+	type face struct {
+		t       *Triangle
+		polygon []V4	// the clipped triangle in raster coordinates
+	}
+	faces := make([]face, 0, len(model.triangles))
+	for i := range model.triangles {
+		t := &model.triangles[i]
+		if polygon := p.screen(t); polygon != nil {
+			faces = append(faces, face{t, polygon})
+		}
+	}
+
+	if dc, ok := c.(DepthCanvas); ok {
+		for _, f := range faces {
+			dc.SetColor(light.shade(f.t, f.t.color(col)))
+			// clipped polygons are convex and can be drawn as a fan:
+			for i := 2; i < len(f.polygon); i++ {
+				dc.FillTriangle(f.polygon[0], f.polygon[i-1], f.polygon[i])
+			}
+		}
+		return
+	}
+
+	var depth = func(t *Triangle) float64 {
+		return (t.v1.z + t.v2.z + t.v3.z) / 3
+	}
+	sort.SliceStable(faces, func(i, j int) bool {
+		return depth(faces[i].t) < depth(faces[j].t)
+	})
+	for _, f := range faces {
+		c.SetColor(light.shade(f.t, f.t.color(col)))
+		drawPolygon(c, f.polygon)
+		c.Fill()
+	}
+}
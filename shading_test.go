@@ -0,0 +1,117 @@
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"image/color"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLight_Intensity(t *testing.T) {
+	// counter-clockwise winding, facing the camera:
+	triangle := NewTriangle(.5, .5, -1,  -.5, .5, -1,  -.5, -.5, -1)
+
+	assert.InDelta(t, 1., NewLight(NewV4(0, 0, -1), .2).Intensity(triangle), 1e-6)
+	assert.InDelta(t, .2, NewLight(NewV4(0, 0, 1), .2).Intensity(triangle), 1e-6)
+	assert.InDelta(t, .2, NewLight(NewV4(1, 0, 0), .2).Intensity(triangle), 1e-6)
+	assert.InDelta(t, .2 + .8 * 0.7071067811865476,
+		NewLight(NewV4(1, 0, -1), .2).Intensity(triangle), 1e-6)
+}
+
+func TestDrawSolid_DepthBuffer(t *testing.T) {
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	light := NewLight(NewV4(0, 0, -1), 0)
+	near := &Model{[]Triangle{*NewTriangle(1, 1, -2,  -1, 1, -2,  -1, -1, -2)}}
+	far := &Model{[]Triangle{*NewTriangle(2, 2, -3,  -2, 2, -3,  -2, -2, -3)}}
+
+	// the near triangle wins, regardless of the order of drawing:
+	r := NewRaster(100, 100)
+	DrawSolid(r, NewProjector(100, 90), near, light, red)
+	DrawSolid(r, NewProjector(100, 90), far, light, blue)
+	assert.Equal(t, red, r.Image().RGBAAt(40, 60))
+	assert.Equal(t, blue, r.Image().RGBAAt(30, 80))
+	assert.Equal(t, white, r.Image().RGBAAt(60, 40))
+
+	r = NewRaster(100, 100)
+	DrawSolid(r, NewProjector(100, 90), far, light, blue)
+	DrawSolid(r, NewProjector(100, 90), near, light, red)
+	assert.Equal(t, red, r.Image().RGBAAt(40, 60))
+	assert.Equal(t, blue, r.Image().RGBAAt(30, 80))
+	assert.Equal(t, white, r.Image().RGBAAt(60, 40))
+}
+
+// recorder is a Canvas that records the colors it fills with.
+type recorder struct {
+	color color.Color
+	fills []color.Color
+}
+
+func (r *recorder) Clear()                      {}
+func (r *recorder) SetColor(c color.Color)      { r.color = c }
+func (r *recorder) MoveTo(x float64, y float64) {}
+func (r *recorder) LineTo(x float64, y float64) {}
+func (r *recorder) ClosePath()                  {}
+func (r *recorder) Stroke()                     {}
+func (r *recorder) Fill()                       { r.fills = append(r.fills, r.color) }
+
+func TestDrawSolid_PaintersAlgorithm(t *testing.T) {
+	light := NewLight(NewV4(0, 0, -1), .5)
+	model := &Model{[]Triangle{
+		*NewTriangle(1, 1, -2,  -1, 1, -2,  -1, -1, -2),
+		*NewTriangle(2, 2, 0,  -2, 2, 0,  -2, -2, 0).Apply(RotX(rad(60))).Apply(TransM(NewV4(0, 0, -4))),
+	}}
+
+	c := &recorder{}
+	DrawSolid(c, NewProjector(100, 90), model, light, color.RGBA{200, 200, 200, 255})
+
+	// the farther, dimmer triangle is painted first:
+	assert.Equal(t, []color.Color{
+		color.NRGBA{150, 150, 150, 255},
+		color.NRGBA{200, 200, 200, 255},
+	}, c.fills)
+}
+
+func TestDrawSolid_Orthographic(t *testing.T) {
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	light := NewLight(NewV4(0, 0, -1), 0)
+	near := &Model{[]Triangle{*NewTriangle(1, 1, -2,  -1, 1, -2,  -1, -1, -2)}}
+	far := &Model{[]Triangle{*NewTriangle(1, 1, -3,  -1, 1, -3,  -1, -1, -3)}}
+
+	r := NewRaster(100, 100)
+	DrawSolid(r, NewOrthoProjector(100, 4), near, light, red)
+	DrawSolid(r, NewOrthoProjector(100, 4), far, light, blue)
+	assert.Equal(t, red, r.Image().RGBAAt(40, 60))
+	assert.Equal(t, white, r.Image().RGBAAt(60, 40))
+}
+
+func TestDrawSolid_VertexColors(t *testing.T) {
+	// a unit square with red vertices at y = 0 and blue ones at y = 1:
+	red, blue := color.NRGBA{255, 0, 0, 255}, color.NRGBA{0, 0, 255, 255}
+	model := &Model{[]Triangle{
+		*NewTriangle(0, 0, 0,  1, 0, 0,  0, 1, 0),
+		*NewTriangle(1, 0, 0,  1, 1, 0,  0, 1, 0),
+	}}
+	model.triangles[0].colors = &[3]color.NRGBA{red, red, blue}
+	model.triangles[1].colors = &[3]color.NRGBA{red, blue, blue}
+	model.Apply(TransM(NewV4(-.5, -.5, -2)))
+
+	r := NewRaster(100, 100)
+	DrawSolid(r, NewProjector(100, 52), model, NewLight(NewV4(0, 0, -1), 0), color.Black)
+
+	// flat shading averages the colors of the vertices of each triangle,
+	// where the triangle with two red vertices lies at the top left:
+	assert.Equal(t, color.RGBA{170, 0, 85, 255}, r.Image().RGBAAt(40, 40))
+	assert.Equal(t, color.RGBA{85, 0, 170, 255}, r.Image().RGBAAt(60, 60))
+}
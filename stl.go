@@ -1,4 +1,4 @@
-// A very crude ASCII STL parser.
+// A very crude STL parser and writer for both ASCII and binary files.
 // https://en.wikipedia.org/wiki/STL_(file_format)
 //
 // Limitations: can only parse triangle facets (vertex triplets).
@@ -22,98 +22,364 @@ package main
 import (
 	"io"
 	"bufio"
+	"bytes"
+	"encoding/binary"
 	"fmt"
-	"regexp"
 	"container/list"
 	"math"
+	"strconv"
+	"strings"
 )
 
-var pat = regexp.MustCompile("\\s*vertex\\s+([^ ]+)\\s+([^ ]+)\\s+([^ ]+)\\s*")
+const (
+	stlHeaderSize = 80	// binary STL header, followed by a uint32 facet count
+	stlFacetSize  = 50	// normal, 3 vertices (12 float32s) and a uint16 attribute
+)
+
+// ParseError reports a malformed line in a model file.
+type ParseError struct {
+	Format string	// file format, e.g. "stl"
+	Line   int	// 1-based line number
+	Text   string	// the offending line
+	Msg    string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: line %d: %s: %q", e.Format, e.Line, e.Msg, e.Text)
+}
 
 type STLReader struct {
+	reader  *bufio.Reader
 	scanner *bufio.Scanner
+	line    int	// current line number of ASCII files
+	solid   bool	// whether we're between "solid" and "endsolid"
+	solids  int	// number of solids encountered
+	binary  bool
+	header  bool	// whether the binary header has been read
+	facets  uint32	// number of binary facets left to read
+	facet   uint32	// number of binary facets read
 }
 
+// NewSTLReader returns a reader for either ASCII or binary STL data. The
+// encoding is detected by peeking at the start of the stream and, when the
+// reader can seek, by its size.
 func NewSTLReader(reader io.Reader) *STLReader {
-	return &STLReader{
-		scanner: bufio.NewScanner(reader),
+	size := int64(-1)
+	if s, ok := reader.(io.Seeker); ok {
+		if pos, err := s.Seek(0, io.SeekCurrent); err == nil {
+			if end, err := s.Seek(0, io.SeekEnd); err == nil {
+				if _, err := s.Seek(pos, io.SeekStart); err == nil {
+					size = end - pos
+				}
+			}
+		}
+	}
+	r := &STLReader{reader: bufio.NewReader(reader)}
+	r.binary = isBinarySTL(r.reader, size)
+	if !r.binary {
+		r.scanner = bufio.NewScanner(r.reader)
 	}
+	return r
 }
 
-func (r *STLReader) readVertex() *V4 {
-	for {
-		if !r.scanner.Scan() {
-			return nil
+// isBinarySTL peeks at the start of the stream of the specified size, or -1
+// when unknown, to determine its encoding. ASCII files must start with
+// "solid", but as some exporters also write "solid" into the header of
+// binary files, a binary file is still assumed when its size matches the
+// facet count in the header, or otherwise when the first few hundred bytes
+// do not contain the "facet" or "endsolid" keywords. Streams too short for
+// a binary header are ASCII.
+func isBinarySTL(r *bufio.Reader, size int64) bool {
+	head, _ := r.Peek(512)
+	if !bytes.HasPrefix(bytes.TrimLeft(head, " \t\r\n"), []byte("solid")) {
+		return len(head) > 0
+	}
+	if len(head) < stlHeaderSize + 4 {
+		return false
+	}
+	count := int64(binary.LittleEndian.Uint32(head[stlHeaderSize:]))
+	if size == stlHeaderSize + 4 + count * stlFacetSize {
+		return true
+	}
+	text := bytes.ToLower(head)
+	return !bytes.Contains(text, []byte("facet")) && !bytes.Contains(text, []byte("endsolid"))
+}
+
+func (r *STLReader) errorf(format string, args ...interface{}) error {
+	return &ParseError{
+		Format: "stl",
+		Line:   r.line,
+		Text:   strings.TrimSpace(r.scanner.Text()),
+		Msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+// nextLine returns the whitespace separated fields of the next non-blank
+// line, or nil when the end of the stream is reached.
+func (r *STLReader) nextLine() ([]string, error) {
+	for r.scanner.Scan() {
+		r.line++
+		if fields := strings.Fields(r.scanner.Text()); len(fields) > 0 {
+			return fields, nil
+		}
+	}
+	return nil, r.scanner.Err()
+}
+
+// expect reads the next line, verifies that it starts with the specified
+// keywords and returns the remaining fields.
+func (r *STLReader) expect(keywords ...string) ([]string, error) {
+	fields, err := r.nextLine()
+	if err != nil {
+		return nil, err
+	}
+	keyword := strings.Join(keywords, " ")
+	if fields == nil {
+		return nil, r.errorf("unexpected end of file, expected %q", keyword)
+	}
+	if len(fields) < len(keywords) {
+		return nil, r.errorf("expected %q", keyword)
+	}
+	for i, k := range keywords {
+		if !strings.EqualFold(fields[i], k) {
+			return nil, r.errorf("expected %q", keyword)
+		}
+	}
+	return fields[len(keywords):], nil
+}
+
+// parseV4 parses the x, y and z coordinates of a "vertex" or "facet normal"
+// line.
+func (r *STLReader) parseV4(fields []string) (*V4, error) {
+	if len(fields) != 3 {
+		return nil, r.errorf("expected 3 coordinates, got %d", len(fields))
+	}
+	var coords [3]float64
+	for i, f := range fields {
+		c, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return nil, r.errorf("invalid coordinate %q", f)
+		}
+		coords[i] = c
+	}
+	return NewV4(coords[0], coords[1], coords[2]), nil
+}
+
+// readASCIIFacet reads the next facet from an ASCII stream, validating the
+// enclosing solid/endsolid structure along the way.
+func (r *STLReader) readASCIIFacet() (*Triangle, error) {
+	var fields []string
+	for fields == nil {
+		line, err := r.nextLine()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case line == nil && r.solid:
+			return nil, r.errorf("unexpected end of file, expected %q", "endsolid")
+		case line == nil && r.solids == 0:
+			return nil, r.errorf("unexpected end of file, expected %q", "solid")
+		case line == nil:
+			return nil, io.EOF
+		case !r.solid && strings.EqualFold(line[0], "solid"):
+			r.solid = true
+			r.solids++
+		case !r.solid:
+			return nil, r.errorf("expected %q", "solid")
+		case strings.EqualFold(line[0], "endsolid"):
+			r.solid = false
+		case strings.EqualFold(line[0], "facet"):
+			fields = line
+		default:
+			return nil, r.errorf("expected %q or %q", "facet", "endsolid")
+		}
+	}
+
+	if len(fields) < 2 || !strings.EqualFold(fields[1], "normal") {
+		return nil, r.errorf("expected %q", "facet normal")
+	}
+	if _, err := r.parseV4(fields[2:]); err != nil {
+		return nil, err
+	}
+	if rest, err := r.expect("outer", "loop"); err != nil {
+		return nil, err
+	} else if len(rest) > 0 {
+		return nil, r.errorf("expected %q", "outer loop")
+	}
+
+	var vertices [3]*V4
+	for i := range vertices {
+		rest, err := r.expect("vertex")
+		if err != nil {
+			return nil, err
 		}
-		line := r.scanner.Text()
-		vertices := pat.FindStringSubmatch(line)
-		if len(vertices) != 4 {
-			continue
+		if vertices[i], err = r.parseV4(rest); err != nil {
+			return nil, err
 		}
-		var x, y, z float64
-		fmt.Sscanf(vertices[1], "%f", &x)
-		fmt.Sscanf(vertices[2], "%f", &y)
-		fmt.Sscanf(vertices[3], "%f", &z)
-		return NewV4(x, y, z)
 	}
+
+	for _, keyword := range []string{"endloop", "endfacet"} {
+		if rest, err := r.expect(keyword); err != nil {
+			return nil, err
+		} else if len(rest) > 0 {
+			return nil, r.errorf("expected %q", keyword)
+		}
+	}
+	return &Triangle{v1: *vertices[0], v2: *vertices[1], v3: *vertices[2]}, nil
+}
+
+// readBinaryFacet reads the next 50-byte facet record from a binary stream,
+// reading past the header on the first invocation.
+func (r *STLReader) readBinaryFacet() (*Triangle, error) {
+	if !r.header {
+		// first facet: skip the header and read the facet count
+		if _, err := r.reader.Discard(stlHeaderSize); err != nil {
+			return nil, fmt.Errorf("stl: truncated binary header: %w", io.ErrUnexpectedEOF)
+		}
+		if err := binary.Read(r.reader, binary.LittleEndian, &r.facets); err != nil {
+			return nil, fmt.Errorf("stl: truncated binary header: %w", io.ErrUnexpectedEOF)
+		}
+		r.header = true
+	}
+	if r.facets == 0 {
+		return nil, io.EOF
+	}
+
+	var facet [stlFacetSize]byte
+	if _, err := io.ReadFull(r.reader, facet[:]); err != nil {
+		return nil, fmt.Errorf("stl: truncated facet %d of %d: %w",
+			r.facet + 1, r.facet + r.facets, io.ErrUnexpectedEOF)
+	}
+	r.facet++
+	r.facets--
+
+	var coord = func(i int) float64 {
+		// skips the normal vector stored in the first 3 floats
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(facet[12+i*4:])))
+	}
+	return NewTriangle(
+		coord(0), coord(1), coord(2),
+		coord(3), coord(4), coord(5),
+		coord(6), coord(7), coord(8)), nil
 }
 
 // ReadTriangle returns the next triangle (facet) from the stream.
-// When the end of the file is reached, nil is returned.
-func (r *STLReader) ReadTriangle() *Triangle {
-	v1 := r.readVertex()
-	v2 := r.readVertex()
-	v3 := r.readVertex()
-	if v1 ==  nil {
-		return nil
-	} else if v2 == nil || v3 == nil {
-		panic("Invalid number of vertices in STL file.")
+// When the end of the file is reached, io.EOF is returned. Malformed ASCII
+// files produce a *ParseError.
+func (r *STLReader) ReadTriangle() (*Triangle, error) {
+	if r.binary {
+		return r.readBinaryFacet()
 	}
-	return &Triangle{v1: *v1, v2: *v2, v3: *v3}
+	return r.readASCIIFacet()
 }
 
 // ReadModel returns the model as defined in the loaded STL file.
 // Models can be scaled to fit in the ((-1, -1, -1), ..., (1, 1, 1))
 // bounding box using the `scale` parameter. Use `scale=false` to keep
 // the STL file's original vertex values.
-func (r *STLReader) ReadModel(scale bool) *Model {
-	var minV = func(v1 *V4, v2 *V4) *V4 {
-		return NewV4(math.Min(v1.x, v2.x), math.Min(v1.y, v2.y), math.Min(v1.z, v2.z))
-	}
-	var maxV = func(v1 *V4, v2 *V4) *V4 {
-		return NewV4(math.Max(v1.x, v2.x), math.Max(v1.y, v2.y), math.Max(v1.z, v2.z))
-	}
+func (r *STLReader) ReadModel(scale bool) (*Model, error) {
+	return readModel(r, scale)
+}
+
+// TriangleReader is implemented by the readers of model files.
+type TriangleReader interface {
+	// ReadTriangle returns the next triangle, or io.EOF at the end.
+	ReadTriangle() (*Triangle, error)
+}
 
+// readModel returns a model of all triangles read from r, optionally
+// normalized to the unit cube.
+func readModel(r TriangleReader, scale bool) (*Model, error) {
 	elements := list.New()
-	for t := r.ReadTriangle(); t != nil; t = r.ReadTriangle() {
+	for {
+		t, err := r.ReadTriangle()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
 		elements.PushBack(t)
 	}
-	var min, max *V4
 	model := Model{make([]Triangle, elements.Len())}
 	for i, e := 0, elements.Front(); e != nil; e = e.Next() {
-		t := e.Value.(*Triangle)
-		if i == 0 {
-			min, max = &t.v1, &t.v1
-		}
-		model.triangles[i] = *t
+		model.triangles[i] = *e.Value.(*Triangle)
+		i++
+	}
+
+	if scale {
+		model.Normalize()
+	}
+	return &model, nil
+}
+
+// STLWriter serializes models as ASCII or binary STL.
+type STLWriter struct {
+	writer io.Writer
+	binary bool
+}
+
+// NewSTLWriter returns a writer that produces binary STL when `binary` is
+// true and ASCII STL otherwise.
+func NewSTLWriter(writer io.Writer, binary bool) *STLWriter {
+	return &STLWriter{writer: writer, binary: binary}
+}
 
+// facetNormal returns the unit normal of the triangle as written to STL
+// files, which is zero for degenerate triangles.
+func facetNormal(t *Triangle) V4 {
+	n := t.Normal()
+	return *n.Normalize()
+}
+
+// WriteModel writes all of the model's triangles, with facet normals
+// computed from their vertex winding.
+func (w *STLWriter) WriteModel(model *Model) error {
+	if w.binary {
+		return w.writeBinary(model)
+	}
+	return w.writeASCII(model)
+}
+
+func (w *STLWriter) writeASCII(model *Model) error {
+	out := bufio.NewWriter(w.writer)
+	fmt.Fprintln(out, "solid model")
+	for i := range model.triangles {
+		t := &model.triangles[i]
+		n := facetNormal(t)
+		fmt.Fprintf(out, "  facet normal %e %e %e\n", n.x, n.y, n.z)
+		fmt.Fprintln(out, "    outer loop")
 		for _, v := range []V4{t.v1, t.v2, t.v3} {
-			min = minV(min, &v)
-			max = maxV(max, &v)
+			fmt.Fprintf(out, "      vertex %e %e %e\n", v.x, v.y, v.z)
 		}
-		i++
+		fmt.Fprintln(out, "    endloop")
+		fmt.Fprintln(out, "  endfacet")
 	}
+	fmt.Fprintln(out, "endsolid model")
+	return out.Flush()
+}
+
+func (w *STLWriter) writeBinary(model *Model) error {
+	out := bufio.NewWriter(w.writer)
 
-	if scale && elements.Len() > 0 {
-		// translate model to the origin:
-		model.Move(
-			((max.x - min.x) / 2.) - max.x,
-			((max.y - min.y) / 2.) - max.y,
-			((max.z - min.z) / 2.) - max.z)
+	// the header must not start with "solid" to not be mistaken for ASCII
+	header := make([]byte, stlHeaderSize)
+	copy(header, "binary STL")
+	out.Write(header)
+	binary.Write(out, binary.LittleEndian, uint32(len(model.triangles)))
 
-		// and scale it to fill the ((-.5, -.5, -.5), (.5, .5, .5)) bounding box:
-		factor := 1. / math.Max(max.x - min.x, math.Max(max.y - min.y, max.z - min.z))
-		model.Apply(ScaleM(factor, factor, factor))
+	var facet [stlFacetSize]byte
+	for i := range model.triangles {
+		t := &model.triangles[i]
+		n := facetNormal(t)
+		for j, v := range []V4{n, t.v1, t.v2, t.v3} {
+			binary.LittleEndian.PutUint32(facet[j*12:], math.Float32bits(float32(v.x)))
+			binary.LittleEndian.PutUint32(facet[j*12+4:], math.Float32bits(float32(v.y)))
+			binary.LittleEndian.PutUint32(facet[j*12+8:], math.Float32bits(float32(v.z)))
+		}
+		// facet[48:50] is the attribute byte count, which is left zero
+		if _, err := out.Write(facet[:]); err != nil {
+			return err
+		}
 	}
-	return &model
+	return out.Flush()
 }
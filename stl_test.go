@@ -14,11 +14,35 @@
 package main
 
 import (
+	"bytes"
+	"encoding/binary"
 	"strings"
 	"fmt"
+	"io"
+	"os"
+	"testing"
+	"github.com/stretchr/testify/assert"
 )
 
-func ExampleStlReader() {
+// binarySTL encodes the specified triangles as a binary STL file with the
+// given header.
+func binarySTL(header string, triangles ...*Triangle) []byte {
+	buf := new(bytes.Buffer)
+	h := make([]byte, 80)
+	copy(h, header)
+	buf.Write(h)
+	binary.Write(buf, binary.LittleEndian, uint32(len(triangles)))
+	for _, t := range triangles {
+		n := t.Normal()
+		for _, v := range []V4{n, t.v1, t.v2, t.v3} {
+			binary.Write(buf, binary.LittleEndian, []float32{float32(v.x), float32(v.y), float32(v.z)})
+		}
+		binary.Write(buf, binary.LittleEndian, uint16(0))
+	}
+	return buf.Bytes()
+}
+
+func ExampleSTLReader() {
 	var stl = `solid Object01
 	  facet normal -1.583127e-002 1.253177e-001 9.919903e-001
 		outer loop
@@ -43,12 +67,12 @@ func ExampleStlReader() {
 	fmt.Println(reader.ReadTriangle())
 
 	// Output:
-	// &{{-21.31976 -10.33176 39.37008 1} {-21.31976 -0.5408154 38.13319 1} {-23.75467 -0.8484154 38.13319 1}}
-	// &{{-21.31976 -10.33176 39.37008 1} {-23.75467 -0.8484154 38.13319 1} {-26.03659 -1.75189 38.13319 1}}
-	// <nil>
+	// &{{-21.31976 -10.33176 39.37008 1} {-21.31976 -0.5408154 38.13319 1} {-23.75467 -0.8484154 38.13319 1} <nil>} <nil>
+	// &{{-21.31976 -10.33176 39.37008 1} {-23.75467 -0.8484154 38.13319 1} {-26.03659 -1.75189 38.13319 1} <nil>} <nil>
+	// <nil> EOF
 }
 
-func ExampleScalingStlReader() {
+func ExampleSTLReader_scaling() {
 	var stl = `solid Object01
 	  facet normal -1.583127e-002 1.253177e-001 9.919903e-001
 		outer loop
@@ -64,6 +88,122 @@ func ExampleScalingStlReader() {
 	fmt.Println(reader.ReadModel(true))
 
 	// Output:
-	// &{[{{-0.3333333333333333 -0.5 -0.3333333333333333 1} {0.3333333333333333 0.16666666666666666 0.3333333333333333 1} {0.3333333333333333 0.5 0.3333333333333333 1}}]}
+	// &{[{{-0.3333333333333333 -0.5 -0.3333333333333333 1} {0.3333333333333333 0.16666666666666666 0.3333333333333333 1} {0.3333333333333333 0.5 0.3333333333333333 1} <nil>}]} <nil>
+
+}
+
+func ExampleSTLReader_binary() {
+	stl := binarySTL("binary STL",
+		NewTriangle(0, 0, 0,  1, 0, 0,  1, 1, 0),
+		NewTriangle(0, 0, 0,  1, 1, 0,  0, 1, .5))
+
+	reader := NewSTLReader(bytes.NewReader(stl))
+	fmt.Println(reader.ReadTriangle())
+	fmt.Println(reader.ReadTriangle())
+	fmt.Println(reader.ReadTriangle())
+
+	// Output:
+	// &{{0 0 0 1} {1 0 0 1} {1 1 0 1} <nil>} <nil>
+	// &{{0 0 0 1} {1 1 0 1} {0 1 0.5 1} <nil>} <nil>
+	// <nil> EOF
+}
+
+func ExampleSTLReader_solidHeader() {
+	// Some exporters start the header of binary files with "solid" too:
+	stl := binarySTL("solid part exported as binary",
+		NewTriangle(-2, -2, -2,  0, 0, 0,  0, 1, 0))
+
+	reader := NewSTLReader(bytes.NewReader(stl))
+	fmt.Println(reader.ReadModel(true))
+
+	// Output:
+	// &{[{{-0.3333333333333333 -0.5 -0.3333333333333333 1} {0.3333333333333333 0.16666666666666666 0.3333333333333333 1} {0.3333333333333333 0.5 0.3333333333333333 1} <nil>}]} <nil>
+}
+
+func TestSTLReader_Encoding(t *testing.T) {
+	ascii := "solid Flügel\n  facet normal 0 0 1\n    outer loop\n      vertex 0 0 0\n" +
+		"      vertex 1 0 0\n      vertex 1 1 0\n    endloop\n  endfacet\nendsolid Flügel\n"
+	binary := binarySTL("solid Flügel", NewTriangle(0, 0, 0,  1, 0, 0,  1, 1, 0))
+
+	// some binary files have trailing bytes after their facets:
+	padded := append(append([]byte(nil), binary...), 0, 0, 0, 0)
+
+	for _, stl := range []string{ascii, string(binary), string(padded)} {
+		// with and without a known size:
+		for _, r := range []io.Reader{strings.NewReader(stl), io.MultiReader(strings.NewReader(stl))} {
+			model, err := NewSTLReader(r).ReadModel(false)
+			assert.NoError(t, err)
+			assert.Equal(t, []Triangle{*NewTriangle(0, 0, 0,  1, 0, 0,  1, 1, 0)}, model.triangles)
+		}
+	}
+}
+
+func assertSTLRoundTrip(t *testing.T, binary bool) {
+	model := Cube().Rot(rad(30), rad(12), 0)
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, NewSTLWriter(buf, binary).WriteModel(model))
+	read, err := NewSTLReader(buf).ReadModel(false)
+	assert.NoError(t, err)
+
+	assert.Equal(t, len(model.triangles), len(read.triangles))
+	for i := range model.triangles {
+		assertAlmostEqualV4(t, model.triangles[i].v1, read.triangles[i].v1)
+		assertAlmostEqualV4(t, model.triangles[i].v2, read.triangles[i].v2)
+		assertAlmostEqualV4(t, model.triangles[i].v3, read.triangles[i].v3)
+	}
+}
+
+func TestSTLWriter_ASCII(t *testing.T) {
+	assertSTLRoundTrip(t, false)
+}
+
+func TestSTLWriter_Binary(t *testing.T) {
+	assertSTLRoundTrip(t, true)
+}
+
+func ExampleSTLWriter() {
+	model := &Model{[]Triangle{*NewTriangle(0, 0, 0,  1, 0, 0,  0, 1, 0)}}
+	NewSTLWriter(os.Stdout, false).WriteModel(model)
+
+	// Output:
+	// solid model
+	//   facet normal 0.000000e+00 0.000000e+00 1.000000e+00
+	//     outer loop
+	//       vertex 0.000000e+00 0.000000e+00 0.000000e+00
+	//       vertex 1.000000e+00 0.000000e+00 0.000000e+00
+	//       vertex 0.000000e+00 1.000000e+00 0.000000e+00
+	//     endloop
+	//   endfacet
+	// endsolid model
+}
+
+func TestSTLReader_Errors(t *testing.T) {
+	for _, c := range []struct {
+		stl string
+		err string
+	}{
+		{"", `stl: line 0: unexpected end of file, expected "solid": ""`},
+		{"solid x\n  facet normal 0 0 1\n    outer loop\n      vertex 1 2 3\n      vertex 1 2,5 3\n",
+			`stl: line 5: invalid coordinate "2,5": "vertex 1 2,5 3"`},
+		{"solid x\n  facet normal 0 0 1\n    outer loop\n      vertex 1 2 3\n      vertex 1 2 3\n    endloop\n",
+			`stl: line 6: expected "vertex": "endloop"`},
+		{"solid x\n  facet normal 0 0 1\n    outer loop\n      vertex 1 2 3\n      vertex 1 2 3\n",
+			`stl: line 5: unexpected end of file, expected "vertex": ""`},
+		{"solid x\n  facet normal 0 0 1\n    outer loop\n      vertex 1 2\n",
+			`stl: line 4: expected 3 coordinates, got 2: "vertex 1 2"`},
+		{"solid x\n  facet 0 0 1\n", `stl: line 2: expected "facet normal": "facet 0 0 1"`},
+		{"solid x\n  vertex 1 2 3\n", `stl: line 2: expected "facet" or "endsolid": "vertex 1 2 3"`},
+		{"solid x\n", `stl: line 1: unexpected end of file, expected "endsolid": ""`},
+	} {
+		_, err := NewSTLReader(strings.NewReader(c.stl)).ReadModel(false)
+		assert.EqualError(t, err, c.err)
+		assert.IsType(t, &ParseError{}, err)
+	}
 
+	stl := binarySTL("binary", NewTriangle(0, 0, 0,  1, 0, 0,  1, 1, 0))
+	stl[80] = 2	// claims 2 facets
+	_, err := NewSTLReader(bytes.NewReader(stl)).ReadModel(false)
+	assert.EqualError(t, err, "stl: truncated facet 2 of 2: unexpected EOF")
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
 }
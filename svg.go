@@ -0,0 +1,145 @@
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+)
+
+// SVG is a Canvas that records what is drawn as paths in an SVG document, so
+// models can be exported as vector illustrations.
+type SVG struct {
+	width       int
+	height      int
+	strokeWidth float64
+	color       color.NRGBA	// stroke and fill color
+	path        bytes.Buffer	// path data of the current path
+	elements    bytes.Buffer	// the elements drawn so far
+}
+
+// NewSVG returns an empty document of the specified size in pixels that
+// draws in black with a stroke width of 1.
+func NewSVG(width int, height int) *SVG {
+	return &SVG{width: width, height: height, strokeWidth: 1, color: color.NRGBA{A: 255}}
+}
+
+// SetStrokeWidth sets the width in pixels of subsequently stroked paths.
+func (s *SVG) SetStrokeWidth(width float64) {
+	s.strokeWidth = width
+}
+
+// Clear discards everything drawn so far, leaving a transparent document.
+func (s *SVG) Clear() {
+	s.path.Reset()
+	s.elements.Reset()
+}
+
+func (s *SVG) SetColor(c color.Color) {
+	s.color = color.NRGBAModel.Convert(c).(color.NRGBA)
+}
+
+func (s *SVG) MoveTo(x float64, y float64) {
+	fmt.Fprintf(&s.path, "M%s %s", svgNumber(x), svgNumber(y))
+}
+
+func (s *SVG) LineTo(x float64, y float64) {
+	if s.path.Len() == 0 {
+		s.MoveTo(x, y)
+		return
+	}
+	fmt.Fprintf(&s.path, "L%s %s", svgNumber(x), svgNumber(y))
+}
+
+func (s *SVG) ClosePath() {
+	if s.path.Len() > 0 {
+		s.path.WriteString("Z")
+	}
+}
+
+func (s *SVG) Stroke() {
+	if s.path.Len() > 0 {
+		fmt.Fprintf(&s.elements,
+			"<path d=\"%s\" fill=\"none\" stroke=\"%s\" stroke-opacity=\"%s\" stroke-width=\"%s\" stroke-linecap=\"round\" stroke-linejoin=\"round\"/>\n",
+			s.path.String(), s.hexColor(), s.opacity(), svgNumber(s.strokeWidth))
+		s.path.Reset()
+	}
+}
+
+// Fill fills the current path using the even-odd rule, like Raster.
+func (s *SVG) Fill() {
+	if s.path.Len() > 0 {
+		fmt.Fprintf(&s.elements,
+			"<path d=\"%s\" fill=\"%s\" fill-opacity=\"%s\" fill-rule=\"evenodd\"/>\n",
+			s.path.String(), s.hexColor(), s.opacity())
+		s.path.Reset()
+	}
+}
+
+func (s *SVG) hexColor() string {
+	return fmt.Sprintf("#%02x%02x%02x", s.color.R, s.color.G, s.color.B)
+}
+
+func (s *SVG) opacity() string {
+	return svgNumber(float64(s.color.A) / 255)
+}
+
+// svgNumber formats a coordinate with a hundredth of a pixel precision,
+// which keeps documents small.
+func svgNumber(v float64) string {
+	v = math.Round(v * 100) / 100
+	if v == 0 {
+		v = 0	// avoids "-0"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// WriteSVG writes the document to w.
+func (s *SVG) WriteSVG(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintf(out, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n",
+		s.width, s.height, s.width, s.height)
+	out.Write(s.elements.Bytes())
+	fmt.Fprintln(out, "</svg>")
+	return out.Flush()
+}
+
+// SVGOptions configures the wireframes exported by ExportSVG.
+type SVGOptions struct {
+	StrokeWidth  float64	// in pixels
+	Color        color.Color
+	HideCoplanar bool	// leaves out the edges between coplanar triangles
+	Hidden       HiddenLines
+}
+
+// ExportSVG renders the wireframe of the model, as seen by the camera
+// through the projector, to an SVG document the size of the projector's
+// raster.
+func ExportSVG(w io.Writer, model *Model, camera *Camera, p *Projector, opts SVGOptions) error {
+	s := NewSVG(p.Size())
+	if opts.StrokeWidth > 0 {
+		s.SetStrokeWidth(opts.StrokeWidth)
+	}
+	if opts.Color != nil {
+		s.SetColor(opts.Color)
+	}
+	NewWireframe(model).Draw(s, p, camera.View(), opts.HideCoplanar, opts.Hidden)
+	return s.WriteSVG(w)
+}
@@ -0,0 +1,87 @@
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/color"
+	"strings"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+type svgDocument struct {
+	Width  int       `xml:"width,attr"`
+	Height int       `xml:"height,attr"`
+	Paths  []svgPath `xml:"path"`
+}
+
+type svgPath struct {
+	D           string `xml:"d,attr"`
+	Fill        string `xml:"fill,attr"`
+	Stroke      string `xml:"stroke,attr"`
+	StrokeWidth string `xml:"stroke-width,attr"`
+}
+
+func TestSVG(t *testing.T) {
+	s := NewSVG(100, 50)
+	s.MoveTo(10, 10)
+	s.LineTo(20.123, -0.001)
+	s.Stroke()
+
+	s.SetColor(color.RGBA{255, 0, 0, 255})
+	s.MoveTo(0, 0)
+	s.LineTo(10, 0)
+	s.LineTo(10, 10)
+	s.ClosePath()
+	s.Fill()
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, s.WriteSVG(buf))
+
+	var doc svgDocument
+	assert.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(t, 100, doc.Width)
+	assert.Equal(t, 50, doc.Height)
+	assert.Equal(t, []svgPath{
+		{D: "M10 10L20.12 0", Fill: "none", Stroke: "#000000", StrokeWidth: "1"},
+		{D: "M0 0L10 0L10 10Z", Fill: "#ff0000"},
+	}, doc.Paths)
+
+	s.Clear()
+	buf.Reset()
+	assert.NoError(t, s.WriteSVG(buf))
+	assert.NotContains(t, buf.String(), "<path")
+}
+
+func TestExportSVG(t *testing.T) {
+	camera := NewCamera(NewV4(0, 0, 2), NewV4(0, 0, 0), NewV4(0, 1, 0))
+	buf := new(bytes.Buffer)
+	assert.NoError(t, ExportSVG(buf, Cube(), camera, NewProjector(200, 52), SVGOptions{
+		StrokeWidth:  .5,
+		Color:        color.RGBA{0, 0, 255, 255},
+		HideCoplanar: true,
+	}))
+
+	var doc svgDocument
+	assert.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(t, 200, doc.Width)
+	assert.Len(t, doc.Paths, 1)
+	assert.Equal(t, "#0000ff", doc.Paths[0].Stroke)
+	assert.Equal(t, "0.5", doc.Paths[0].StrokeWidth)
+
+	// the front face of the cube, as a square of 4 lines:
+	assert.Equal(t, 4, strings.Count(doc.Paths[0].D, "L"))
+}
@@ -0,0 +1,364 @@
+// A reader for 3MF packages, the zip archives of XML mesh data written by
+// modern slicers.
+// https://github.com/3MFConsortium/spec_core/blob/master/3MF%20Core%20Specification.md
+//
+// Limitations: only reads the geometry of mesh objects and components,
+// including those in other model parts (production extension). Materials,
+// colors and metadata are ignored. Models are converted to millimeters by
+// the unit of the root model part only, which other parts are assumed to
+// share.
+//
+//
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	threeMFRelationships = "_rels/.rels"
+	threeMFDefaultModel  = "3D/3dmodel.model"
+	threeMFModelType     = "http://schemas.microsoft.com/3dmanufacturing/2013/01/3dmodel"
+
+	// threeMFMaxTriangles limits the size of objects and of the build,
+	// which guards against components that reference the same objects
+	// over and over.
+	threeMFMaxTriangles = 1 << 23
+)
+
+// threeMFUnits holds the size in millimeters of the units of model parts.
+var threeMFUnits = map[string]float64{
+	"micron":     .001,
+	"millimeter": 1,
+	"centimeter": 10,
+	"inch":       25.4,
+	"foot":       304.8,
+	"meter":      1000,
+}
+
+// threeMFPart is the XML document of a model part of the package.
+type threeMFPart struct {
+	Unit    string             `xml:"unit,attr"`
+	Objects []threeMFObject    `xml:"resources>object"`
+	Items   []threeMFComponent `xml:"build>item"`
+}
+
+type threeMFObject struct {
+	ID       int `xml:"id,attr"`
+	Vertices []struct {
+		X float64 `xml:"x,attr"`
+		Y float64 `xml:"y,attr"`
+		Z float64 `xml:"z,attr"`
+	} `xml:"mesh>vertices>vertex"`
+	Triangles []struct {
+		V1 int `xml:"v1,attr"`
+		V2 int `xml:"v2,attr"`
+		V3 int `xml:"v3,attr"`
+	} `xml:"mesh>triangles>triangle"`
+	Components []threeMFComponent `xml:"components>component"`
+}
+
+// threeMFComponent is a reference to an object, either as a component of
+// another object or as a build item.
+type threeMFComponent struct {
+	ObjectID  int    `xml:"objectid,attr"`
+	Transform string `xml:"transform,attr"`
+	Path      string `xml:"http://schemas.microsoft.com/3dmanufacturing/production/2015/06 path,attr"`
+}
+
+// threeMFRef identifies an object by its model part and id.
+type threeMFRef struct {
+	part string
+	id   int
+}
+
+// ThreeMFReader reads the build of a 3MF package as a single model.
+type ThreeMFReader struct {
+	archive *zip.Reader
+	parts   map[string]*threeMFPart	// the model parts parsed so far, by path
+	objects map[threeMFRef]*Model	// the objects built so far
+	sizes   map[threeMFRef]int	// the objects' numbers of triangles counted so far
+	stack   map[threeMFRef]bool	// the objects being counted
+}
+
+// NewThreeMFReader opens the 3MF package of the specified size.
+func NewThreeMFReader(reader io.ReaderAt, size int64) (*ThreeMFReader, error) {
+	archive, err := zip.NewReader(reader, size)
+	if err != nil {
+		return nil, fmt.Errorf("3mf: %w", err)
+	}
+	return &ThreeMFReader{
+		archive: archive,
+		parts:   make(map[string]*threeMFPart),
+		objects: make(map[threeMFRef]*Model),
+		sizes:   make(map[threeMFRef]int),
+		stack:   make(map[threeMFRef]bool),
+	}, nil
+}
+
+// open returns the package's file at the specified absolute or relative
+// path.
+func (r *ThreeMFReader) open(name string) (io.ReadCloser, error) {
+	name = strings.TrimPrefix(name, "/")
+	for _, f := range r.archive.File {
+		if strings.EqualFold(f.Name, name) {
+			return f.Open()
+		}
+	}
+	return nil, fmt.Errorf("3mf: missing %s", name)
+}
+
+// rootPart returns the path of the root model part, as declared in the
+// package relationships.
+func (r *ThreeMFReader) rootPart() (string, error) {
+	rc, err := r.open(threeMFRelationships)
+	if err != nil {
+		return "/" + threeMFDefaultModel, nil
+	}
+	defer rc.Close()
+
+	var rels struct {
+		Relationships []struct {
+			Target string `xml:"Target,attr"`
+			Type   string `xml:"Type,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := xml.NewDecoder(rc).Decode(&rels); err != nil {
+		return "", fmt.Errorf("3mf: %s: %w", threeMFRelationships, err)
+	}
+	for _, rel := range rels.Relationships {
+		if rel.Type == threeMFModelType {
+			return path.Join("/", rel.Target), nil
+		}
+	}
+	return "", fmt.Errorf("3mf: %s: no model relationship", threeMFRelationships)
+}
+
+// part returns the parsed model part at the specified path.
+func (r *ThreeMFReader) part(name string) (*threeMFPart, error) {
+	if p, found := r.parts[name]; found {
+		return p, nil
+	}
+	rc, err := r.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	p := &threeMFPart{}
+	if err := xml.NewDecoder(rc).Decode(p); err != nil {
+		return nil, fmt.Errorf("3mf: %s: %w", name, err)
+	}
+	r.parts[name] = p
+	return p, nil
+}
+
+// parseTransform converts the 12 values of a transform attribute, which
+// are the columns of a matrix that transforms row vectors, to an M4. An
+// empty attribute is the identity.
+func parseTransform(s string) (*M4, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return new(M4).SetIdentity(), nil
+	}
+	if len(fields) != 12 {
+		return nil, fmt.Errorf("invalid transform %q", s)
+	}
+	var m [12]float64
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transform %q", s)
+		}
+		m[i] = v
+	}
+	return &M4{
+		m[0], m[3], m[6], m[9],
+		m[1], m[4], m[7], m[10],
+		m[2], m[5], m[8], m[11],
+		0, 0, 0, 1}, nil
+}
+
+// transform applies the component's transform to the model. Mirroring
+// transforms also reverse the winding of the triangles, to keep them facing
+// outwards.
+func (c *threeMFComponent) transform(model *Model) (*Model, error) {
+	m, err := parseTransform(c.Transform)
+	if err != nil {
+		return nil, err
+	}
+	model.Apply(m)
+	if m.Determinant() < 0 {
+		for i := range model.triangles {
+			t := &model.triangles[i]
+			t.v2, t.v3 = t.v3, t.v2
+		}
+	}
+	return model, nil
+}
+
+// find returns the object with the specified id in the model part.
+func (r *ThreeMFReader) find(partName string, id int) (*threeMFObject, error) {
+	p, err := r.part(partName)
+	if err != nil {
+		return nil, err
+	}
+	for i := range p.Objects {
+		if p.Objects[i].ID == id {
+			return &p.Objects[i], nil
+		}
+	}
+	return nil, fmt.Errorf("3mf: %s: missing object %d", partName, id)
+}
+
+// refPart returns the model part of the referenced object.
+func (c *threeMFComponent) refPart(partName string) string {
+	if c.Path != "" {
+		return path.Join("/", c.Path)
+	}
+	return partName
+}
+
+// size returns the number of triangles of the referenced objects, including
+// their components, without building them. This rejects cyclic references
+// and, as objects may be referenced many times, counts above
+// threeMFMaxTriangles before any geometry is built.
+func (r *ThreeMFReader) size(partName string, refs []threeMFComponent) (int, error) {
+	total := 0
+	for _, c := range refs {
+		ref := threeMFRef{c.refPart(partName), c.ObjectID}
+		n, found := r.sizes[ref]
+		if !found {
+			if r.stack[ref] {
+				return 0, fmt.Errorf("3mf: %s: object %d: cyclic component reference", ref.part, ref.id)
+			}
+			o, err := r.find(ref.part, ref.id)
+			if err != nil {
+				return 0, err
+			}
+			r.stack[ref] = true
+			n, err = r.size(ref.part, o.Components)
+			delete(r.stack, ref)
+			if err != nil {
+				return 0, err
+			}
+			n += len(o.Triangles)
+			r.sizes[ref] = n
+		}
+		if total += n; total > threeMFMaxTriangles {
+			return 0, fmt.Errorf("3mf: %s: more than %d triangles", partName, threeMFMaxTriangles)
+		}
+	}
+	return total, nil
+}
+
+// object returns the geometry of the object with the specified id in the
+// model part, which includes its transformed components. Objects are built
+// once and shared by all their references, so the model must not be
+// modified.
+func (r *ThreeMFReader) object(partName string, id int) (*Model, error) {
+	ref := threeMFRef{partName, id}
+	if model, found := r.objects[ref]; found {
+		return model, nil
+	}
+	o, err := r.find(partName, id)
+	if err != nil {
+		return nil, err
+	}
+
+	model := &Model{make([]Triangle, len(o.Triangles))}
+	for i, t := range o.Triangles {
+		for _, v := range []int{t.V1, t.V2, t.V3} {
+			if v < 0 || v >= len(o.Vertices) {
+				return nil, fmt.Errorf("3mf: %s: object %d: triangle %d: vertex %d out of range",
+					partName, id, i, v)
+			}
+		}
+		v1, v2, v3 := o.Vertices[t.V1], o.Vertices[t.V2], o.Vertices[t.V3]
+		model.triangles[i] = *NewTriangle(v1.X, v1.Y, v1.Z,  v2.X, v2.Y, v2.Z,  v3.X, v3.Y, v3.Z)
+	}
+
+	components, err := r.instances(partName, o.Components)
+	if err != nil {
+		return nil, err
+	}
+	model = model.Merge(components...)
+	r.objects[ref] = model
+	return model, nil
+}
+
+// instances returns the transformed geometry of the referenced objects,
+// which may live in other model parts.
+func (r *ThreeMFReader) instances(partName string, refs []threeMFComponent) ([]Model, error) {
+	var instances []Model
+	for _, c := range refs {
+		name := c.refPart(partName)
+		object, err := r.object(name, c.ObjectID)
+		if err != nil {
+			return nil, err
+		}
+		model, err := c.transform(object.Clone())
+		if err != nil {
+			return nil, fmt.Errorf("3mf: %s: object %d: %w", name, c.ObjectID, err)
+		}
+		instances = append(instances, *model)
+	}
+	return instances, nil
+}
+
+// ReadModel returns the merged geometry of all items of the package's
+// build, each placed by its transform, in millimeters. Models can be scaled
+// to fit in the unit cube like STLReader.ReadModel.
+func (r *ThreeMFReader) ReadModel(scale bool) (*Model, error) {
+	root, err := r.rootPart()
+	if err != nil {
+		return nil, err
+	}
+	p, err := r.part(root)
+	if err != nil {
+		return nil, err
+	}
+
+	unit := 1.
+	if p.Unit != "" {
+		var ok bool
+		if unit, ok = threeMFUnits[p.Unit]; !ok {
+			return nil, fmt.Errorf("3mf: %s: unknown unit %q", root, p.Unit)
+		}
+	}
+
+	if _, err := r.size(root, p.Items); err != nil {
+		return nil, err
+	}
+	items, err := r.instances(root, p.Items)
+	if err != nil {
+		return nil, err
+	}
+	model := new(Model).Merge(items...)
+	if unit != 1 {
+		model.Apply(ScaleM(unit, unit, unit))
+	}
+	if scale {
+		model.Normalize()
+	}
+	return model, nil
+}
@@ -0,0 +1,198 @@
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+const threeMFRels = `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+  <Relationship Target="/3D/3dmodel.model" Id="rel0" Type="http://schemas.microsoft.com/3dmanufacturing/2013/01/3dmodel"/>
+</Relationships>`
+
+// threeMFTriangle is an object with a single triangle in the xy-plane.
+const threeMFTriangle = `
+    <object id="%d" type="model">
+      <mesh>
+        <vertices>
+          <vertex x="0" y="0" z="0"/>
+          <vertex x="1" y="0" z="0"/>
+          <vertex x="0" y="1" z="0"/>
+        </vertices>
+        <triangles>
+          <triangle v1="0" v2="1" v3="2"/>
+        </triangles>
+      </mesh>
+    </object>`
+
+// threeMFModel returns a model part with the specified resources and build.
+func threeMFModel(resources string, build string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<model unit="millimeter" xml:lang="en-US" xmlns="http://schemas.microsoft.com/3dmanufacturing/core/2015/02"
+    xmlns:p="http://schemas.microsoft.com/3dmanufacturing/production/2015/06">
+  <resources>` + resources + `
+  </resources>
+  <build>` + build + `
+  </build>
+</model>`
+}
+
+// threeMFPackage zips the files into a 3MF package and opens it.
+func threeMFPackage(t *testing.T, files map[string]string) *ThreeMFReader {
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		assert.NoError(t, err)
+		f.Write([]byte(content))
+	}
+	assert.NoError(t, w.Close())
+
+	r, err := NewThreeMFReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+	return r
+}
+
+func TestThreeMFReader(t *testing.T) {
+	r := threeMFPackage(t, map[string]string{
+		"_rels/.rels": threeMFRels,
+		"3D/3dmodel.model": threeMFModel(
+			fmt.Sprintf(threeMFTriangle, 1) + `
+    <object id="2" type="model">
+      <components>
+        <component objectid="1" transform="1 0 0 0 1 0 0 0 1 0 0 5"/>
+      </components>
+    </object>`,
+			`
+    <item objectid="1" transform="1 0 0 0 1 0 0 0 1 10 0 0"/>
+    <item objectid="2"/>`),
+	})
+
+	model, err := r.ReadModel(false)
+	assert.NoError(t, err)
+	assert.Len(t, model.triangles, 2)
+
+	// the items are placed by their transforms:
+	assert.Equal(t, *NewTriangle(10, 0, 0,  11, 0, 0,  10, 1, 0), model.triangles[0])
+	assert.Equal(t, *NewTriangle(0, 0, 5,  1, 0, 5,  0, 1, 5), model.triangles[1])
+
+	model, err = r.ReadModel(true)
+	assert.NoError(t, err)
+	b := model.Bounds()
+	assert.InDelta(t, 1, b.Size().x, 1e-9)
+}
+
+func TestThreeMFReader_Transforms(t *testing.T) {
+	// rotating by 90 degrees about the z-axis, which maps x onto y:
+	m, err := parseTransform("0 1 0 -1 0 0 0 0 1 1 2 3")
+	assert.NoError(t, err)
+	assertAlmostEqualM4(t, TransM(NewV4(1, 2, 3)).Mul(RotZ(rad(90))), m, 1e-9)
+
+	_, err = parseTransform("1 0 0")
+	assert.EqualError(t, err, `invalid transform "1 0 0"`)
+
+	// mirroring keeps triangles facing outwards:
+	r := threeMFPackage(t, map[string]string{
+		"3D/3dmodel.model": threeMFModel(fmt.Sprintf(threeMFTriangle, 1),
+			`<item objectid="1" transform="1 0 0 0 1 0 0 0 -1 0 0 0"/>`),
+	})
+	model, err := r.ReadModel(false)
+	assert.NoError(t, err)
+	n := model.triangles[0].Normal()
+	assertAlmostEqualV4(t, *NewV4(0, 0, -1), *n.Normalize())
+}
+
+func TestThreeMFReader_Production(t *testing.T) {
+	// the production extension moves objects into their own parts:
+	r := threeMFPackage(t, map[string]string{
+		"_rels/.rels":             threeMFRels,
+		"3D/Objects/object.model": threeMFModel(fmt.Sprintf(threeMFTriangle, 7), ""),
+		"3D/3dmodel.model": threeMFModel(`
+    <object id="1" type="model">
+      <components>
+        <component p:path="/3D/Objects/object.model" objectid="7"/>
+        <component p:path="/3D/Objects/object.model" objectid="7" transform="1 0 0 0 1 0 0 0 1 0 0 1"/>
+      </components>
+    </object>`,
+			`<item objectid="1"/>`),
+	})
+	model, err := r.ReadModel(false)
+	assert.NoError(t, err)
+	assert.Len(t, model.triangles, 2)
+	assertAlmostEqualV4(t, *NewV4(0, 0, 1), model.triangles[1].v1)
+}
+
+func TestThreeMFReader_Units(t *testing.T) {
+	model := threeMFModel(fmt.Sprintf(threeMFTriangle, 1), `<item objectid="1" transform="1 0 0 0 1 0 0 0 1 0 0 1"/>`)
+	r := threeMFPackage(t, map[string]string{
+		"3D/3dmodel.model": strings.Replace(model, `unit="millimeter"`, `unit="inch"`, 1),
+	})
+	m, err := r.ReadModel(false)
+	assert.NoError(t, err)
+	assertAlmostEqualV4(t, *NewV4(25.4, 0, 25.4), m.triangles[0].v2)
+
+	r = threeMFPackage(t, map[string]string{
+		"3D/3dmodel.model": strings.Replace(model, `unit="millimeter"`, `unit="parsec"`, 1),
+	})
+	_, err = r.ReadModel(false)
+	assert.EqualError(t, err, `3mf: /3D/3dmodel.model: unknown unit "parsec"`)
+}
+
+func TestThreeMFReader_SharedObjects(t *testing.T) {
+	// every object has two copies of the next, for 2^40 triangles in total:
+	resources := fmt.Sprintf(threeMFTriangle, 40)
+	for id := 0; id < 40; id++ {
+		resources += fmt.Sprintf(`
+    <object id="%d"><components><component objectid="%d"/><component objectid="%d"/></components></object>`,
+			id, id + 1, id + 1)
+	}
+	r := threeMFPackage(t, map[string]string{
+		"3D/3dmodel.model": threeMFModel(resources, `<item objectid="0"/>`),
+	})
+	_, err := r.ReadModel(false)
+	assert.EqualError(t, err, fmt.Sprintf("3mf: /3D/3dmodel.model: more than %d triangles", threeMFMaxTriangles))
+}
+
+func TestThreeMFReader_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		files map[string]string
+		err   string
+	}{
+		{map[string]string{}, "3mf: missing 3D/3dmodel.model"},
+		{map[string]string{"3D/3dmodel.model": "<model>"}, "3mf: /3D/3dmodel.model: XML syntax error on line 1: unexpected EOF"},
+		{map[string]string{"3D/3dmodel.model": threeMFModel("", `<item objectid="1"/>`)},
+			"3mf: /3D/3dmodel.model: missing object 1"},
+		{map[string]string{"3D/3dmodel.model": threeMFModel(fmt.Sprintf(threeMFTriangle, 1), `<item objectid="1" transform="1 0"/>`)},
+			`3mf: /3D/3dmodel.model: object 1: invalid transform "1 0"`},
+		{map[string]string{"3D/3dmodel.model": threeMFModel(`
+    <object id="1"><mesh><vertices><vertex x="0" y="0" z="0"/></vertices>
+      <triangles><triangle v1="0" v2="0" v3="1"/></triangles></mesh></object>`, `<item objectid="1"/>`)},
+			"3mf: /3D/3dmodel.model: object 1: triangle 0: vertex 1 out of range"},
+		{map[string]string{"3D/3dmodel.model": threeMFModel(`
+    <object id="1"><components><component objectid="1"/></components></object>`, `<item objectid="1"/>`)},
+			"3mf: /3D/3dmodel.model: object 1: cyclic component reference"},
+	} {
+		_, err := threeMFPackage(t, tc.files).ReadModel(false)
+		assert.EqualError(t, err, tc.err)
+	}
+
+	_, err := NewThreeMFReader(bytes.NewReader([]byte("solid")), 5)
+	assert.Error(t, err)
+}
@@ -0,0 +1,113 @@
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"math"
+)
+
+// Turntable returns the orientation of the model at the specified angle of
+// the turntable rotation the renderer animates, about the model's z-axis,
+// which is turned upright and then tilted towards the camera by `tilt`. Both
+// angles are in radians.
+func Turntable(tilt float64, angle float64) *M4 {
+	return NewQuatEuler(math.Pi / 2, tilt, angle).M4()
+}
+
+// GIFOptions configures the animations exported by ExportGIF.
+type GIFOptions struct {
+	Frames       int	// number of frames in one full rotation, defaults to 36
+	Delay        int	// delay between frames in 100ths of a second, defaults to 10
+	LoopCount    int	// as in gif.GIF: 0 loops forever, -1 shows the frames once
+	Tilt         float64	// of the turntable's axis in radians, see Turntable
+	Start        float64	// turntable angle of the first frame in radians
+	Solid        bool	// flat shaded rather than wireframe
+	Light        *Light	// for solid models, defaults to a light from the top left
+	Color        color.Color	// of the solid model or wireframe
+	HideCoplanar bool	// leaves out the wireframe's edges between coplanar triangles
+	Hidden       HiddenLines
+}
+
+// ExportGIF renders one full turntable rotation of the model, as seen by the
+// camera through the projector, with a fixed angle between frames and
+// encodes the frames as an animated GIF.
+func ExportGIF(w io.Writer, model *Model, camera *Camera, p *Projector, opts GIFOptions) error {
+	if opts.Frames <= 0 {
+		opts.Frames = 36
+	}
+	if opts.Delay <= 0 {
+		opts.Delay = 10
+	}
+	if opts.Light == nil {
+		opts.Light = NewLight(NewV4(1, 1, -1), .2)
+	}
+	if opts.Color == nil {
+		opts.Color = color.Black
+		if opts.Solid {
+			opts.Color = color.RGBA{0x70, 0x90, 0xc0, 0xff}
+		}
+	}
+
+	var batches []Batch
+	var wireframe *Wireframe
+	if opts.Solid {
+		batches = NewBatches(model, batchSize)
+	} else {
+		wireframe = NewWireframe(model)
+	}
+
+	width, height := p.Size()
+	pal := turntablePalette(opts.Color)
+	anim := &gif.GIF{LoopCount: opts.LoopCount}
+	for i := 0; i < opts.Frames; i++ {
+		m := camera.View().Mul(Turntable(opts.Tilt, opts.Start + 2 * math.Pi * float64(i) / float64(opts.Frames)))
+
+		r := NewRaster(width, height)
+		if opts.Solid {
+			DrawSolid(r, p, Cull(batches, p.Frustum(), m), opts.Light, opts.Color)
+		} else {
+			r.SetColor(opts.Color)
+			wireframe.Draw(r, p, m, opts.HideCoplanar, opts.Hidden)
+		}
+
+		frame := image.NewPaletted(r.Image().Bounds(), pal)
+		draw.Draw(frame, frame.Bounds(), r.Image(), image.Point{}, draw.Src)
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, opts.Delay)
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// turntablePalette returns a palette of the white background and the shades
+// of the specified color from black to full intensity, which covers both
+// wireframes and flat shaded models.
+func turntablePalette(c color.Color) color.Palette {
+	rgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+	pal := color.Palette{color.White}
+	for i := 0; i < 255; i++ {
+		f := float64(i) / 254
+		pal = append(pal, color.RGBA{
+			R: uint8(float64(rgba.R) * f),
+			G: uint8(float64(rgba.G) * f),
+			B: uint8(float64(rgba.B) * f),
+			A: 255,
+		})
+	}
+	return pal
+}
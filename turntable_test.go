@@ -0,0 +1,60 @@
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bytes"
+	"image/gif"
+	"math"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTurntable(t *testing.T) {
+	// a full rotation returns to the start:
+	assertAlmostEqualV4(t, *NewV4(1, 2, 3).MultiplyM(Turntable(rad(23.4), 0)),
+		*NewV4(1, 2, 3).MultiplyM(Turntable(rad(23.4), 2 * math.Pi)))
+}
+
+func TestExportGIF(t *testing.T) {
+	camera := NewCamera(NewV4(0, 0, 2), NewV4(0, 0, 0), NewV4(0, 1, 0))
+	p := NewProjector(40, 52)
+
+	for _, solid := range []bool{false, true} {
+		// the cube looks the same every quarter turn, so use 3 frames:
+		opts := GIFOptions{Frames: 3, Delay: 5, LoopCount: -1, Tilt: rad(23.4), Solid: solid}
+		buf := new(bytes.Buffer)
+		assert.NoError(t, ExportGIF(buf, Cube(), camera, p, opts))
+
+		// frames are deterministic:
+		again := new(bytes.Buffer)
+		assert.NoError(t, ExportGIF(again, Cube(), camera, p, opts))
+		assert.Equal(t, buf.Bytes(), again.Bytes())
+
+		anim, err := gif.DecodeAll(buf)
+		assert.NoError(t, err)
+		assert.Len(t, anim.Image, 3)
+		assert.Equal(t, []int{5, 5, 5}, anim.Delay)
+		assert.Equal(t, -1, anim.LoopCount)
+		assert.NotEqual(t, anim.Image[0].Pix, anim.Image[1].Pix)
+
+		// starting at the second frame's angle:
+		opts.Start = 2 * math.Pi / 3
+		buf.Reset()
+		assert.NoError(t, ExportGIF(buf, Cube(), camera, p, opts))
+		started, err := gif.DecodeAll(buf)
+		assert.NoError(t, err)
+		assert.Equal(t, anim.Image[1].Pix, started.Image[0].Pix)
+	}
+}
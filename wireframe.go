@@ -0,0 +1,272 @@
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// Wireframe is the edge list of a model, for drawing every edge shared by
+// adjacent triangles only once.
+type Wireframe struct {
+	mesh   *Mesh
+	edges  []wireEdge
+	bounds Sphere
+}
+
+type wireEdge struct {
+	Edge
+	faces    []int	// the adjacent faces
+	coplanar bool	// whether the edge lies between two faces in one plane
+}
+
+// coplanarity is the minimum cosine of the angle between the normals of
+// adjacent faces that are considered to lie in the same plane.
+const coplanarity = 1 - 1e-9
+
+// HiddenLines selects how a wireframe treats edges that are occluded by the
+// model itself.
+type HiddenLines int
+
+const (
+	CullBackFaces HiddenLines = iota	// draws all edges of the faces that face the camera
+	RemoveHidden	// leaves out the occluded parts of edges
+	DashHidden	// draws the occluded parts of edges dashed
+)
+
+const (
+	dashLength     = 4	// in pixels
+	dashGap        = 3	// in pixels
+	edgeTolerance  = 1e-6	// margin in pixels by which occluders are grown
+	minSpan        = 1e-3	// length in pixels below which occluded parts are ignored
+	depthTolerance = 1e-9	// depth difference within which a point is on an occluder
+)
+
+// NewWireframe builds the edge list of the specified model.
+func NewWireframe(model *Model) *Wireframe {
+	mesh := weld(model)
+	w := &Wireframe{mesh: mesh, bounds: model.BoundingSphere()}
+
+	normals := make([]V4, len(mesh.faces))
+	for i, f := range mesh.faces {
+		t := Triangle{v1: mesh.vertices[f[0]], v2: mesh.vertices[f[1]], v3: mesh.vertices[f[2]]}
+		n := t.Normal()
+		normals[i] = *n.Normalize()
+	}
+
+	faces := mesh.EdgeFaces()
+	for _, e := range mesh.Edges() {
+		adjacent := faces[e]
+		w.edges = append(w.edges, wireEdge{
+			Edge:     e,
+			faces:    adjacent,
+			coplanar: len(adjacent) == 2 && Dot(normals[adjacent[0]], normals[adjacent[1]]) >= coplanarity,
+		})
+	}
+	return w
+}
+
+// Draw strokes the edges of the wireframe after transforming it into camera
+// space with m. Use `hideCoplanar` to leave out the edges between faces that
+// lie in the same plane, like the diagonals of quads.
+//
+// With CullBackFaces, edges are drawn when at least one of their faces faces
+// the camera, which only hides all occluded edges of convex models. The
+// other modes compute which parts of edges are occluded by the faces in
+// front of them analytically, so that the output remains vector graphics.
+// Edges are clipped to the view frustum.
+func (w *Wireframe) Draw(c Canvas, p *Projector, m *M4, hideCoplanar bool, hidden HiddenLines) {
+	if !p.Frustum().IntersectsSphere(w.bounds.Transform(m)) {
+		return
+	}
+	mesh := w.mesh.Clone().Apply(m)
+
+	facing := make([]bool, len(mesh.faces))
+	var occluders []occluder
+	for i, f := range mesh.faces {
+		t := Triangle{v1: mesh.vertices[f[0]], v2: mesh.vertices[f[1]], v3: mesh.vertices[f[2]]}
+		facing[i] = p.facing(&t)
+		if facing[i] && hidden != CullBackFaces {
+			if polygon := p.screen(&t); polygon != nil {
+				occluders = append(occluders, newOccluder(i, polygon))
+			}
+		}
+	}
+
+	for _, e := range w.edges {
+		if hideCoplanar && e.coplanar {
+			continue
+		}
+		visible := false
+		for _, f := range e.faces {
+			visible = visible || facing[f]
+		}
+		if !visible && hidden != DashHidden {
+			continue
+		}
+		a, b, ok := p.clipLine(mesh.vertices[e.a], mesh.vertices[e.b])
+		if !ok {
+			continue
+		}
+		a, b = p.toScreen(a), p.toScreen(b)
+
+		switch {
+		case hidden == CullBackFaces:
+			drawLine(c, a, b, 0, 1)
+		case !visible:
+			drawDashes(c, a, b, 0, 1)
+		default:
+			t := 0.
+			for _, span := range occlusion(a, b, e.faces, occluders) {
+				drawLine(c, a, b, t, span[0])
+				if hidden == DashHidden {
+					drawDashes(c, a, b, span[0], span[1])
+				}
+				t = span[1]
+			}
+			drawLine(c, a, b, t, 1)
+		}
+	}
+	c.Stroke()
+}
+
+// occluder is a face in front of the camera in raster coordinates.
+type occluder struct {
+	face     int
+	polygon  []V4
+	min, max V2	// the bounding box of the polygon
+	plane    V4	// the face's plane, as the depth z = x * plane.x + y * plane.y + plane.z
+}
+
+func newOccluder(face int, polygon []V4) occluder {
+	o := occluder{face: face, polygon: polygon, min: V2{polygon[0].x, polygon[0].y}, max: V2{polygon[0].x, polygon[0].y}}
+
+	// Newell's method, which is robust against collinear vertices:
+	var n, center V4
+	for i, v := range polygon {
+		u := polygon[(i + 1) % len(polygon)]
+		n.x += (v.y - u.y) * (v.z + u.z)
+		n.y += (v.z - u.z) * (v.x + u.x)
+		n.z += (v.x - u.x) * (v.y + u.y)
+		center.x += v.x / float64(len(polygon))
+		center.y += v.y / float64(len(polygon))
+		center.z += v.z / float64(len(polygon))
+
+		o.min.x, o.min.y = math.Min(o.min.x, v.x), math.Min(o.min.y, v.y)
+		o.max.x, o.max.y = math.Max(o.max.x, v.x), math.Max(o.max.y, v.y)
+	}
+	o.plane = V4{
+		x: -n.x / n.z,
+		y: -n.y / n.z,
+		z: center.z + (n.x * center.x + n.y * center.y) / n.z,
+	}
+	return o
+}
+
+// occlusion returns the sorted, disjoint parameter intervals of the raster
+// space line segment from a to b that lie behind any of the occluders, not
+// counting the edge's own faces.
+func occlusion(a V4, b V4, faces []int, occluders []occluder) [][2]float64 {
+	var spans [][2]float64
+	next:
+	for i := range occluders {
+		o := &occluders[i]
+		if math.Max(a.x, b.x) < o.min.x || math.Min(a.x, b.x) > o.max.x ||
+			math.Max(a.y, b.y) < o.min.y || math.Min(a.y, b.y) > o.max.y {
+			continue
+		}
+		for _, f := range faces {
+			if f == o.face {
+				continue next
+			}
+		}
+
+		// clips the segment to the inside of the polygon, which has a
+		// positive area and so lies left of its edges. The polygon is grown
+		// by a small margin to not leave gaps between adjacent occluders:
+		t0, t1 := 0., 1.
+		for j, v := range o.polygon {
+			u := o.polygon[(j + 1) % len(o.polygon)]
+			ex, ey := u.x - v.x, u.y - v.y
+			l := math.Hypot(ex, ey)
+			if l == 0 {
+				continue
+			}
+			da := (ex * (a.y - v.y) - ey * (a.x - v.x)) / l + edgeTolerance
+			db := (ex * (b.y - v.y) - ey * (b.x - v.x)) / l + edgeTolerance
+			if da <= 0 && db <= 0 {
+				continue next
+			} else if da <= 0 {
+				t0 = math.Max(t0, da / (da - db))
+			} else if db <= 0 {
+				t1 = math.Min(t1, da / (da - db))
+			}
+		}
+		if t0 >= t1 {
+			continue
+		}
+
+		// the segment is occluded where it lies behind (has a smaller depth
+		// than) the occluder, which changes at most once as both are planar:
+		depth := func(t float64) float64 {
+			x, y, z := a.x + (b.x - a.x) * t, a.y + (b.y - a.y) * t, a.z + (b.z - a.z) * t
+			return z - (x * o.plane.x + y * o.plane.y + o.plane.z) + depthTolerance
+		}
+		d0, d1 := depth(t0), depth(t1)
+		switch {
+		case d0 >= 0 && d1 >= 0:
+			continue
+		case d0 < 0 && d1 >= 0:
+			t1 = t0 + (t1 - t0) * d0 / (d0 - d1)
+		case d0 >= 0 && d1 < 0:
+			t0 = t0 + (t1 - t0) * d0 / (d0 - d1)
+		}
+		// rounding errors cause slivers where edges meet occluders:
+		if (t1 - t0) * math.Hypot(b.x - a.x, b.y - a.y) >= minSpan {
+			spans = append(spans, [2]float64{t0, t1})
+		}
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+	var merged [][2]float64
+	for _, s := range spans {
+		if n := len(merged); n > 0 && s[0] <= merged[n-1][1] {
+			merged[n-1][1] = math.Max(merged[n-1][1], s[1])
+		} else {
+			merged = append(merged, s)
+		}
+	}
+	return merged
+}
+
+// drawLine adds the part of the raster space line segment from a to b
+// between the parameters t0 and t1 to the canvas' path.
+func drawLine(c Canvas, a V4, b V4, t0 float64, t1 float64) {
+	if t1 > t0 {
+		c.MoveTo(a.x + (b.x - a.x) * t0, a.y + (b.y - a.y) * t0)
+		c.LineTo(a.x + (b.x - a.x) * t1, a.y + (b.y - a.y) * t1)
+	}
+}
+
+// drawDashes is like drawLine, but breaks the line up into dashes.
+func drawDashes(c Canvas, a V4, b V4, t0 float64, t1 float64) {
+	l := math.Hypot(b.x - a.x, b.y - a.y)
+	if l == 0 {
+		return
+	}
+	for t := t0; t < t1; t += (dashLength + dashGap) / l {
+		drawLine(c, a, b, t, math.Min(t + dashLength / l, t1))
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2018 Erik van Zijst -- erik.van.zijst@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"image/color"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+// lineCounter is a Canvas that counts the line segments it is asked to draw.
+type lineCounter struct {
+	lines   int
+	strokes int
+}
+
+func (c *lineCounter) Clear()                      {}
+func (c *lineCounter) SetColor(col color.Color)    {}
+func (c *lineCounter) MoveTo(x float64, y float64) {}
+func (c *lineCounter) LineTo(x float64, y float64) { c.lines++ }
+func (c *lineCounter) ClosePath()                  {}
+func (c *lineCounter) Stroke()                     { c.strokes++ }
+func (c *lineCounter) Fill()                       {}
+
+func TestNewWireframe(t *testing.T) {
+	w := NewWireframe(Cube())
+	assert.Len(t, w.edges, 18)
+
+	coplanar := 0
+	for _, e := range w.edges {
+		assert.Len(t, e.faces, 2)
+		if e.coplanar {
+			coplanar++
+		}
+	}
+	// the diagonal of each of the six quads:
+	assert.Equal(t, 6, coplanar)
+}
+
+func TestWireframe_Draw(t *testing.T) {
+	p := NewProjector(100, 52)
+	w := NewWireframe(Cube())
+
+	// only the front face is visible, drawn as 4 sides and a diagonal:
+	c := &lineCounter{}
+	w.Draw(c, p, TransM(NewV4(0, 0, -2)), false, CullBackFaces)
+	assert.Equal(t, 5, c.lines)
+	assert.Equal(t, 1, c.strokes)
+
+	c = &lineCounter{}
+	w.Draw(c, p, TransM(NewV4(0, 0, -2)), true, CullBackFaces)
+	assert.Equal(t, 4, c.lines)
+
+	// three faces of a cube seen along its diagonal share their sides:
+	c = &lineCounter{}
+	w.Draw(c, p, TransM(NewV4(0, 0, -3)).Mul(RotX(rad(35.26))).Mul(RotY(rad(45))), true, CullBackFaces)
+	assert.Equal(t, 9, c.lines)
+
+	// the same holds for parallel projections:
+	c = &lineCounter{}
+	w.Draw(c, NewOrthoProjector(100, 4), TransM(NewV4(0, 0, -3)).Mul(RotX(rad(35.26))).Mul(RotY(rad(45))), true, CullBackFaces)
+	assert.Equal(t, 9, c.lines)
+
+	// nothing is drawn behind the camera:
+	c = &lineCounter{}
+	w.Draw(c, p, TransM(NewV4(0, 0, 2)), false, CullBackFaces)
+	assert.Equal(t, 0, c.lines)
+}
+
+func TestWireframe_HiddenLines(t *testing.T) {
+	p := NewProjector(100, 52)
+
+	// a wide strip behind a square, whose long edges pass behind it:
+	w := NewWireframe(&Model{[]Triangle{
+		*NewTriangle(.5, .5, -2,  -.5, .5, -2,  -.5, -.5, -2),
+		*NewTriangle(-.5, -.5, -2,  .5, -.5, -2,  .5, .5, -2),
+		*NewTriangle(1, .25, -3,  -1, .25, -3,  -1, -.25, -3),
+		*NewTriangle(-1, -.25, -3,  1, -.25, -3,  1, .25, -3),
+	}})
+	identity := new(M4).SetIdentity()
+
+	c := &lineCounter{}
+	w.Draw(c, p, identity, true, CullBackFaces)
+	assert.Equal(t, 8, c.lines)
+
+	// the long edges are split into their visible ends:
+	c = &lineCounter{}
+	w.Draw(c, p, identity, true, RemoveHidden)
+	assert.Equal(t, 10, c.lines)
+
+	c = &lineCounter{}
+	w.Draw(c, p, identity, true, DashHidden)
+	assert.True(t, c.lines > 10)
+
+	// the edges of the back faces of a cube are all hidden:
+	cube := NewWireframe(Cube())
+	m := TransM(NewV4(0, 0, -3)).Mul(RotX(rad(35.26))).Mul(RotY(rad(45)))
+	c = &lineCounter{}
+	cube.Draw(c, p, m, true, RemoveHidden)
+	assert.Equal(t, 9, c.lines)
+
+	c = &lineCounter{}
+	cube.Draw(c, p, m, true, DashHidden)
+	assert.True(t, c.lines > 9)
+}
+
+func TestOcclusion(t *testing.T) {
+	square := newOccluder(0, []V4{
+		{x: 0, y: 0, z: .5}, {x: 10, y: 0, z: .5}, {x: 10, y: 10, z: .5}, {x: 0, y: 10, z: .5},
+	})
+
+	// behind the square for its middle half:
+	spans := occlusion(V4{x: -10, y: 5, z: .4}, V4{x: 20, y: 5, z: .4}, nil, []occluder{square})
+	assert.Len(t, spans, 1)
+	assert.InDelta(t, 1. / 3, spans[0][0], 1e-6)
+	assert.InDelta(t, 2. / 3, spans[0][1], 1e-6)
+
+	// in front of the square:
+	assert.Empty(t, occlusion(V4{x: -10, y: 5, z: .6}, V4{x: 20, y: 5, z: .6}, nil, []occluder{square}))
+
+	// piercing the square halfway:
+	spans = occlusion(V4{x: 0, y: 5, z: .6}, V4{x: 10, y: 5, z: .4}, nil, []occluder{square})
+	assert.Len(t, spans, 1)
+	assert.InDelta(t, .5, spans[0][0], 1e-6)
+	assert.InDelta(t, 1, spans[0][1], 1e-6)
+
+	// not occluded by its own face:
+	assert.Empty(t, occlusion(V4{x: -10, y: 5, z: .4}, V4{x: 20, y: 5, z: .4}, []int{0}, []occluder{square}))
+}
+
+func TestProjector_ClipLine(t *testing.T) {
+	p := NewProjector(100, 90)
+
+	// a line crossing the near plane is cut off at it:
+	a, b, ok := p.clipLine(*NewV4(0, 0, -5), *NewV4(0, 0, 5))
+	assert.True(t, ok)
+	assertInClipVolume(t, []V4{a, b})
+	assert.InDelta(t, -1, b.z / b.w, 1e-9)
+
+	_, _, ok = p.clipLine(*NewV4(0, 0, 1), *NewV4(0, 0, 5))
+	assert.False(t, ok)
+}